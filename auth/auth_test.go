@@ -0,0 +1,114 @@
+package auth_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/bcrypt"
+
+	"gofiber-api/auth"
+)
+
+type AuthTestSuite struct {
+	suite.Suite
+	signer *auth.Signer
+}
+
+func TestAuthTestSuite(t *testing.T) {
+	suite.Run(t, new(AuthTestSuite))
+}
+
+func (s *AuthTestSuite) SetupTest() {
+	s.signer = auth.NewSigner([]byte("the-secret"), time.Hour)
+}
+
+func (s *AuthTestSuite) TestIssueAndVerify() {
+	token, err := s.signer.Issue(auth.Identity{Username: "the-user", Role: "admin"})
+	s.NoError(err)
+	s.WithinDuration(time.Now().Add(time.Hour), token.ExpiresAt, time.Minute)
+
+	identity, err := s.signer.Verify(token.Token)
+	s.NoError(err)
+	s.Equal("the-user", identity.Username)
+	s.Equal("admin", identity.Role)
+}
+
+func (s *AuthTestSuite) TestRejectTamperedToken() {
+	token, err := s.signer.Issue(auth.Identity{Username: "the-user", Role: "user"})
+	s.NoError(err)
+
+	payload, signature, _ := strings.Cut(token.Token, ".")
+	forged, err := s.signer.Issue(auth.Identity{Username: "the-user", Role: "admin"})
+	s.NoError(err)
+	forgedPayload, _, _ := strings.Cut(forged.Token, ".")
+
+	for _, tampered := range []string{
+		forgedPayload + "." + signature,
+		payload + "." + signature + "x",
+		payload,
+		"",
+	} {
+		_, err := s.signer.Verify(tampered)
+		s.ErrorIs(err, auth.ErrInvalidToken, tampered)
+	}
+
+	other := auth.NewSigner([]byte("another-secret"), time.Hour)
+	_, err = other.Verify(token.Token)
+	s.ErrorIs(err, auth.ErrInvalidToken)
+}
+
+func (s *AuthTestSuite) TestRejectExpiredToken() {
+	expired := auth.NewSigner([]byte("the-secret"), -time.Minute)
+	token, err := expired.Issue(auth.Identity{Username: "the-user", Role: "user"})
+	s.NoError(err)
+
+	_, err = s.signer.Verify(token.Token)
+	s.ErrorIs(err, auth.ErrTokenExpired)
+	s.ErrorIs(err, auth.ErrUnauthorized)
+}
+
+func (s *AuthTestSuite) TestUserStore() {
+	hash, err := bcrypt.GenerateFromPassword([]byte("file-password"), bcrypt.MinCost)
+	s.Require().NoError(err)
+
+	path := filepath.Join(s.T().TempDir(), "users.json")
+	s.Require().NoError(os.WriteFile(path, []byte(`[{"username":"from-file","password_hash":"`+string(hash)+`"}]`), 0o600))
+
+	users, err := auth.LoadUserStore(path)
+	s.Require().NoError(err)
+
+	identity, err := users.Authenticate("from-file", "file-password")
+	s.NoError(err)
+	s.Equal(auth.Identity{Username: "from-file", Role: auth.RoleUser}, identity)
+
+	s.NoError(users.AddUser("added", "added-password", "moderator"))
+	identity, err = users.Authenticate("added", "added-password")
+	s.NoError(err)
+	s.Equal("moderator", identity.Role)
+
+	_, err = users.Authenticate("added", "wrong")
+	s.ErrorIs(err, auth.ErrInvalidCredentials)
+	_, err = users.Authenticate("nobody", "added-password")
+	s.ErrorIs(err, auth.ErrInvalidCredentials)
+}
+
+func (s *AuthTestSuite) TestPolicy() {
+	policy := auth.DefaultPolicy()
+	s.True(policy.Allows(auth.RoleModerator, auth.PermEditAnyThread))
+	s.True(policy.Allows(auth.RoleAdmin, auth.PermDeleteAnyThread))
+	s.False(policy.Allows(auth.RoleUser, auth.PermEditAnyThread))
+	s.False(policy.Allows("unknown", auth.PermDeleteAnyThread))
+
+	path := filepath.Join(s.T().TempDir(), "roles.json")
+	s.Require().NoError(os.WriteFile(path, []byte(`{"janitor":["delete_any_thread"]}`), 0o600))
+
+	policy, err := auth.LoadPolicy(path)
+	s.Require().NoError(err)
+	s.True(policy.Allows("janitor", auth.PermDeleteAnyThread))
+	s.False(policy.Allows("janitor", auth.PermEditAnyThread))
+	s.False(policy.Allows(auth.RoleModerator, auth.PermEditAnyThread))
+}
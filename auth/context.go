@@ -0,0 +1,16 @@
+package auth
+
+import "github.com/gofiber/fiber/v2"
+
+type identityKey struct{}
+
+// SetIdentity records the authenticated caller of the request.
+func SetIdentity(c *fiber.Ctx, identity Identity) {
+	c.Locals(identityKey{}, identity)
+}
+
+// IdentityFrom returns the authenticated caller of the request, if any.
+func IdentityFrom(c *fiber.Ctx) (Identity, bool) {
+	identity, ok := c.Locals(identityKey{}).(Identity)
+	return identity, ok
+}
@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+var ErrForbidden = errors.New("forbidden")
+
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// Permissions granted on top of what an author may do with their own
+// content.
+const (
+	PermEditAnyThread   = "edit_any_thread"
+	PermDeleteAnyThread = "delete_any_thread"
+	// PermManageTrash allows listing deleted threads and restoring them
+	PermManageTrash = "manage_trash"
+	// PermManageWebhooks allows registering webhooks and reading their
+	// deliveries
+	PermManageWebhooks = "manage_webhooks"
+)
+
+// Policy maps roles to the permissions they hold. Roles that are not listed
+// hold none.
+type Policy struct {
+	roles map[string]map[string]bool
+}
+
+func NewPolicy(roles map[string][]string) *Policy {
+	p := &Policy{
+		roles: make(map[string]map[string]bool, len(roles)),
+	}
+	for role, permissions := range roles {
+		p.roles[role] = make(map[string]bool, len(permissions))
+		for _, permission := range permissions {
+			p.roles[role][permission] = true
+		}
+	}
+	return p
+}
+
+// DefaultPolicy lets moderators and admins edit and delete any thread and
+// manage the trash, only admins manage webhooks.
+func DefaultPolicy() *Policy {
+	return NewPolicy(map[string][]string{
+		RoleModerator: {PermEditAnyThread, PermDeleteAnyThread, PermManageTrash},
+		RoleAdmin:     {PermEditAnyThread, PermDeleteAnyThread, PermManageTrash, PermManageWebhooks},
+	})
+}
+
+// LoadPolicy reads a JSON object mapping each role to its permissions.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var roles map[string][]string
+	if err := json.Unmarshal(data, &roles); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return NewPolicy(roles), nil
+}
+
+func (p *Policy) Allows(role string, permission string) bool {
+	return p.roles[role][permission]
+}
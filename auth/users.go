@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const RoleUser = "user"
+
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+}
+
+// UserStore is the local list of accounts allowed to log in. It is safe for
+// concurrent use.
+type UserStore struct {
+	mu    sync.RWMutex
+	users map[string]User
+}
+
+func NewUserStore() *UserStore {
+	return &UserStore{
+		users: make(map[string]User),
+	}
+}
+
+// LoadUserStore reads a JSON array of users with bcrypt password hashes.
+func LoadUserStore(path string) (*UserStore, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+
+	store := NewUserStore()
+	for _, user := range users {
+		if user.Username == "" || user.PasswordHash == "" {
+			return nil, fmt.Errorf("parsing %s: user without username or password hash", path)
+		}
+		if user.Role == "" {
+			user.Role = RoleUser
+		}
+		store.users[user.Username] = user
+	}
+	return store, nil
+}
+
+// AddUser hashes password and stores the account, replacing any account
+// with the same username.
+func (us *UserStore) AddUser(username string, password string, role string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if role == "" {
+		role = RoleUser
+	}
+
+	us.mu.Lock()
+	defer us.mu.Unlock()
+
+	us.users[username] = User{
+		Username:     username,
+		PasswordHash: string(hash),
+		Role:         role,
+	}
+	return nil
+}
+
+func (us *UserStore) Authenticate(username string, password string) (Identity, error) {
+	us.mu.RLock()
+	user, ok := us.users[username]
+	us.mu.RUnlock()
+
+	if !ok {
+		// spend the same time as a wrong password so usernames can't be probed
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		return Identity{}, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return Identity{}, ErrInvalidCredentials
+	}
+
+	return Identity{
+		Username: user.Username,
+		Role:     user.Role,
+	}, nil
+}
+
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
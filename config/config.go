@@ -0,0 +1,278 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	service "gofiber-api/service"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Every flag can also be given in the environment, its name is EnvPrefix
+// followed by the flag name in upper case with dashes turned into
+// underscores, -token-ttl is API_TOKEN_TTL.
+const EnvPrefix = "API_"
+
+// Store kinds.
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+	StoreSqlite = "sqlite"
+)
+
+// MinSecretLen is the shortest auth secret accepted, the length of the
+// HMAC-SHA256 key.
+const MinSecretLen = 32
+
+// Config holds the settings of the server. Load fills it from the defaults,
+// then the config file, then the environment and last the flags, each one
+// overriding the settings given by the ones before.
+type Config struct {
+	Server   Server   `yaml:"server"`
+	Store    Store    `yaml:"store"`
+	Auth     Auth     `yaml:"auth"`
+	Threads  Threads  `yaml:"threads"`
+	Webhooks Webhooks `yaml:"webhooks"`
+	Features Features `yaml:"features"`
+}
+
+type Server struct {
+	Addr   string `yaml:"addr"`
+	Prefix string `yaml:"prefix"`
+	// a zero timeout never expires
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	IdleTimeout  time.Duration `yaml:"idle_timeout"`
+	BodyLimit    int           `yaml:"body_limit"`
+	// the handler timeouts bound the work of a request down to the store,
+	// the stream and sockets are not bounded
+	ReadHandlerTimeout  time.Duration `yaml:"read_handler_timeout"`
+	WriteHandlerTimeout time.Duration `yaml:"write_handler_timeout"`
+	// ShutdownTimeout bounds draining the requests in flight and stopping
+	// the workers and the store on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+type Store struct {
+	Kind    string `yaml:"kind"`
+	DataDir string `yaml:"data_dir"`
+	// EventLog is the file the thread events are appended to, none when empty
+	EventLog string `yaml:"event_log"`
+}
+
+type Auth struct {
+	UsersFile string `yaml:"users_file"`
+	RolesFile string `yaml:"roles_file"`
+	// Secret signs the tokens, a random one is used when empty so the tokens
+	// do not survive a restart
+	Secret   string        `yaml:"secret"`
+	TokenTTL time.Duration `yaml:"token_ttl"`
+}
+
+type Threads struct {
+	MaxReplyDepth  int           `yaml:"max_reply_depth"`
+	TrashRetention time.Duration `yaml:"trash_retention"`
+	PurgeInterval  time.Duration `yaml:"purge_interval"`
+}
+
+type Webhooks struct {
+	Attempts int           `yaml:"attempts"`
+	Backoff  time.Duration `yaml:"backoff"`
+}
+
+// Features turns the optional parts of the API on and off.
+type Features struct {
+	Stream   bool `yaml:"stream"`
+	Sockets  bool `yaml:"sockets"`
+	Webhooks bool `yaml:"webhooks"`
+}
+
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:                ":3001",
+			Prefix:              "/api",
+			ReadTimeout:         10 * time.Second,
+			IdleTimeout:         time.Minute,
+			BodyLimit:           fiber.DefaultBodyLimit,
+			ReadHandlerTimeout:  5 * time.Second,
+			WriteHandlerTimeout: 10 * time.Second,
+			ShutdownTimeout:     15 * time.Second,
+		},
+		Store: Store{
+			Kind:    StoreMemory,
+			DataDir: "data",
+		},
+		Auth: Auth{
+			TokenTTL: 24 * time.Hour,
+		},
+		Threads: Threads{
+			MaxReplyDepth:  service.DefaultMaxReplyDepth,
+			TrashRetention: 30 * 24 * time.Hour,
+			PurgeInterval:  time.Hour,
+		},
+		Webhooks: Webhooks{
+			Attempts: service.DefaultMaxAttempts,
+			Backoff:  service.DefaultRetryBackoff,
+		},
+		Features: Features{
+			Stream:   true,
+			Sockets:  true,
+			Webhooks: true,
+		},
+	}
+}
+
+// Load reads the settings from the config file, the environment looked up
+// with getenv and the command line args, without the program name. The
+// config file is named by -config or API_CONFIG.
+func Load(args []string, getenv func(string) string) (Config, error) {
+	cfg := Default()
+
+	fs := flag.NewFlagSet("gofiber-api", flag.ContinueOnError)
+	path := fs.String("config", getenv(EnvName("config")), "YAML file of the settings, the environment and flags override it")
+	cfg.bind(fs)
+	if err := fs.Parse(args); err != nil {
+		return Config{}, err
+	}
+	// the flags are applied again over the file and the environment
+	given := map[string]string{}
+	fs.Visit(func(f *flag.Flag) {
+		given[f.Name] = f.Value.String()
+	})
+
+	if *path != "" {
+		if err := cfg.readFile(*path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		value := getenv(EnvName(f.Name))
+		if err != nil || f.Name == "config" || value == "" {
+			return
+		}
+		if setErr := fs.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("%s: %w", EnvName(f.Name), setErr)
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	for name, value := range given {
+		if err := fs.Set(name, value); err != nil {
+			return Config{}, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// EnvName is the environment variable of the flag name.
+func EnvName(name string) string {
+	return EnvPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+func (c *Config) bind(fs *flag.FlagSet) {
+	fs.StringVar(&c.Server.Addr, "addr", c.Server.Addr, "address to listen on")
+	fs.StringVar(&c.Server.Prefix, "prefix", c.Server.Prefix, "path the API is served under")
+	fs.DurationVar(&c.Server.ReadTimeout, "read-timeout", c.Server.ReadTimeout, "how long reading a request may take, 0 for no limit")
+	fs.DurationVar(&c.Server.WriteTimeout, "write-timeout", c.Server.WriteTimeout, "how long writing a response may take, 0 for no limit, it cuts the event stream")
+	fs.DurationVar(&c.Server.IdleTimeout, "idle-timeout", c.Server.IdleTimeout, "how long an idle keep-alive connection is kept, 0 for no limit")
+	fs.IntVar(&c.Server.BodyLimit, "body-limit", c.Server.BodyLimit, "largest request body in bytes")
+	fs.DurationVar(&c.Server.ReadHandlerTimeout, "read-handler-timeout", c.Server.ReadHandlerTimeout, "how long a GET may take before it is answered with 504, 0 for no limit")
+	fs.DurationVar(&c.Server.WriteHandlerTimeout, "write-handler-timeout", c.Server.WriteHandlerTimeout, "how long a change may take before it is answered with 504, 0 for no limit")
+	fs.DurationVar(&c.Server.ShutdownTimeout, "shutdown-timeout", c.Server.ShutdownTimeout, "how long the requests in flight are waited for on shutdown")
+
+	fs.StringVar(&c.Store.Kind, "store", c.Store.Kind, "thread store: memory, file or sqlite")
+	fs.StringVar(&c.Store.DataDir, "data", c.Store.DataDir, "directory of the file and sqlite stores")
+	fs.StringVar(&c.Store.EventLog, "event-log", c.Store.EventLog, "file the thread events are appended to as JSON lines")
+
+	fs.StringVar(&c.Auth.UsersFile, "users", c.Auth.UsersFile, "JSON file of the accounts allowed to log in")
+	fs.StringVar(&c.Auth.RolesFile, "roles", c.Auth.RolesFile, "JSON file mapping roles to permissions, moderators and admins manage every thread by default")
+	fs.StringVar(&c.Auth.Secret, "auth-secret", c.Auth.Secret, "secret the tokens are signed with, at least 32 bytes, random when empty")
+	fs.DurationVar(&c.Auth.TokenTTL, "token-ttl", c.Auth.TokenTTL, "lifetime of issued tokens")
+
+	fs.IntVar(&c.Threads.MaxReplyDepth, "max-reply-depth", c.Threads.MaxReplyDepth, "how deep replies may nest")
+	fs.DurationVar(&c.Threads.TrashRetention, "trash-retention", c.Threads.TrashRetention, "how long deleted threads can be restored before they are purged")
+	fs.DurationVar(&c.Threads.PurgeInterval, "purge-interval", c.Threads.PurgeInterval, "how often the trash is purged")
+
+	fs.IntVar(&c.Webhooks.Attempts, "webhook-attempts", c.Webhooks.Attempts, "how many times a webhook delivery is attempted before it is dead")
+	fs.DurationVar(&c.Webhooks.Backoff, "webhook-backoff", c.Webhooks.Backoff, "delay before the first webhook retry, it doubles on every failure")
+
+	fs.BoolVar(&c.Features.Stream, "stream", c.Features.Stream, "serve the server-sent event stream of thread changes")
+	fs.BoolVar(&c.Features.Sockets, "sockets", c.Features.Sockets, "serve the websockets of threads")
+	fs.BoolVar(&c.Features.Webhooks, "webhooks", c.Features.Webhooks, "manage and deliver webhooks")
+}
+
+func (c *Config) readFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	// a misspelled setting would otherwise be silently ignored
+	dec.KnownFields(true)
+	if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return nil
+}
+
+// Validate reports every setting that the server cannot start with.
+func (c Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "addr is required")
+	check(c.Server.Prefix == "" || strings.HasPrefix(c.Server.Prefix, "/") && !strings.HasSuffix(c.Server.Prefix, "/"),
+		"prefix %q must start and must not end with a slash", c.Server.Prefix)
+	check(c.Server.ReadTimeout >= 0, "read timeout must not be negative")
+	check(c.Server.WriteTimeout >= 0, "write timeout must not be negative")
+	check(c.Server.IdleTimeout >= 0, "idle timeout must not be negative")
+	check(c.Server.BodyLimit > 0, "body limit must be positive")
+	check(c.Server.ReadHandlerTimeout >= 0, "read handler timeout must not be negative")
+	check(c.Server.WriteHandlerTimeout >= 0, "write handler timeout must not be negative")
+	check(c.Server.ShutdownTimeout > 0, "shutdown timeout must be positive")
+	// the deadline covers the whole response, it would end every stream
+	check(c.Server.WriteTimeout == 0 || !c.Features.Stream, "write timeout cannot be used with the stream")
+
+	check(c.Store.Kind == StoreMemory || c.Store.Kind == StoreFile || c.Store.Kind == StoreSqlite,
+		"unknown store %q", c.Store.Kind)
+	check(c.Store.Kind == StoreMemory || c.Store.DataDir != "", "data directory is required by the %s store", c.Store.Kind)
+
+	check(c.Auth.Secret == "" || len(c.Auth.Secret) >= MinSecretLen, "auth secret must be at least %d bytes", MinSecretLen)
+	check(c.Auth.TokenTTL > 0, "token ttl must be positive")
+	check(c.Threads.MaxReplyDepth > 0, "max reply depth must be positive")
+	check(c.Threads.TrashRetention >= 0, "trash retention must not be negative")
+	check(c.Threads.PurgeInterval > 0, "purge interval must be positive")
+	check(c.Webhooks.Attempts > 0, "webhook attempts must be positive")
+	check(c.Webhooks.Backoff >= 0, "webhook backoff must not be negative")
+
+	return errors.Join(errs...)
+}
+
+// Fiber is the configuration of the fiber app serving the API. Prefork is
+// not supported, the search index, the event subscribers and the workers
+// live in the process and every child would keep and run its own.
+func (c Config) Fiber() fiber.Config {
+	return fiber.Config{
+		ReadTimeout:  c.Server.ReadTimeout,
+		WriteTimeout: c.Server.WriteTimeout,
+		IdleTimeout:  c.Server.IdleTimeout,
+		BodyLimit:    c.Server.BodyLimit,
+	}
+}
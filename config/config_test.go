@@ -0,0 +1,148 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/config"
+)
+
+type ConfigSuite struct {
+	suite.Suite
+	env map[string]string
+}
+
+func TestConfigSuite(t *testing.T) {
+	suite.Run(t, new(ConfigSuite))
+}
+
+func (s *ConfigSuite) SetupTest() {
+	s.env = map[string]string{}
+}
+
+func (s *ConfigSuite) getenv(name string) string {
+	return s.env[name]
+}
+
+func (s *ConfigSuite) writeFile(content string) string {
+	path := filepath.Join(s.T().TempDir(), "config.yaml")
+	s.Require().NoError(os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func (s *ConfigSuite) TestDefaults() {
+	cfg, err := config.Load(nil, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(config.Default(), cfg)
+	s.Equal(":3001", cfg.Server.Addr)
+	s.Equal("/api", cfg.Server.Prefix)
+	s.Equal(config.StoreMemory, cfg.Store.Kind)
+}
+
+func (s *ConfigSuite) TestPrecedence() {
+	path := s.writeFile(`
+server:
+  addr: ":4000"
+  read_timeout: 5s
+store:
+  kind: sqlite
+  data_dir: /var/lib/threads
+threads:
+  max_reply_depth: 3
+features:
+  webhooks: false
+`)
+
+	cfg, err := config.Load([]string{"-config", path}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(":4000", cfg.Server.Addr)
+	s.Equal(5*time.Second, cfg.Server.ReadTimeout)
+	s.Equal(config.StoreSqlite, cfg.Store.Kind)
+	s.Equal("/var/lib/threads", cfg.Store.DataDir)
+	s.Equal(3, cfg.Threads.MaxReplyDepth)
+	s.False(cfg.Features.Webhooks)
+	// settings missing from the file keep their defaults
+	s.Equal(time.Minute, cfg.Server.IdleTimeout)
+	s.True(cfg.Features.Stream)
+
+	// the environment overrides the file and the flags override both
+	s.env["API_CONFIG"] = path
+	s.env["API_ADDR"] = ":5000"
+	s.env["API_MAX_REPLY_DEPTH"] = "4"
+	s.env["API_READ_TIMEOUT"] = "7s"
+	s.env["API_AUTH_SECRET"] = "an environment secret of 32 bytes"
+	cfg, err = config.Load([]string{"-read-timeout", "9s"}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(":5000", cfg.Server.Addr)
+	s.Equal(4, cfg.Threads.MaxReplyDepth)
+	s.Equal(9*time.Second, cfg.Server.ReadTimeout)
+	s.Equal("an environment secret of 32 bytes", cfg.Auth.Secret)
+	s.Equal(config.StoreSqlite, cfg.Store.Kind)
+
+	// a flag given its default value still wins
+	cfg, err = config.Load([]string{"-addr", ":3001"}, s.getenv)
+	s.Require().NoError(err)
+	s.Equal(":3001", cfg.Server.Addr)
+}
+
+func (s *ConfigSuite) TestRejectsUnknownSettings() {
+	path := s.writeFile("server:\n  adress: \":4000\"\n")
+	_, err := config.Load([]string{"-config", path}, s.getenv)
+	s.ErrorContains(err, "adress")
+
+	// prefork is not supported
+	path = s.writeFile("server:\n  prefork: true\n")
+	_, err = config.Load([]string{"-config", path}, s.getenv)
+	s.ErrorContains(err, "prefork")
+	_, err = config.Load([]string{"-prefork"}, s.getenv)
+	s.Error(err)
+
+	s.env["API_BODY_LIMIT"] = "lots"
+	_, err = config.Load(nil, s.getenv)
+	s.ErrorContains(err, "API_BODY_LIMIT")
+
+	_, err = config.Load([]string{"-unknown"}, s.getenv)
+	s.Error(err)
+}
+
+func (s *ConfigSuite) TestValidate() {
+	cfg := config.Default()
+	s.NoError(cfg.Validate())
+
+	cfg.Store.Kind = "postgres"
+	cfg.Server.Prefix = "api/"
+	cfg.Server.BodyLimit = 0
+	err := cfg.Validate()
+	s.ErrorContains(err, `unknown store "postgres"`)
+	s.ErrorContains(err, "prefix")
+	s.ErrorContains(err, "body limit")
+
+	cfg = config.Default()
+	cfg.Server.WriteTimeout = time.Second
+	s.ErrorContains(cfg.Validate(), "stream")
+	cfg.Features.Stream = false
+	s.NoError(cfg.Validate())
+
+	cfg = config.Default()
+	cfg.Auth.Secret = "too short"
+	s.ErrorContains(cfg.Validate(), "auth secret")
+	cfg.Auth.Secret = "a secret long enough to sign with"
+	s.NoError(cfg.Validate())
+}
+
+func (s *ConfigSuite) TestFiber() {
+	cfg := config.Default()
+	cfg.Server.WriteTimeout = 3 * time.Second
+	cfg.Server.BodyLimit = 1024
+
+	app := cfg.Fiber()
+	s.Equal(10*time.Second, app.ReadTimeout)
+	s.Equal(3*time.Second, app.WriteTimeout)
+	s.Equal(time.Minute, app.IdleTimeout)
+	s.Equal(1024, app.BodyLimit)
+	s.False(app.Prefork)
+}
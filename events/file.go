@@ -0,0 +1,48 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileSink appends every event it is sent to a file as a line of JSON.
+// Events relayed twice are written twice, readers drop repeats by Key.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// OpenFileSink opens path for appending, creating it when needed.
+func OpenFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{file: f}, nil
+}
+
+// Send writes event and syncs the file, the event is only acknowledged
+// once it is durable.
+func (fs *FileSink) Send(ctx context.Context, event Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if _, err := fs.file.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	return fs.file.Sync()
+}
+
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.file.Close()
+}
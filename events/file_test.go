@@ -0,0 +1,53 @@
+package events_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/events"
+)
+
+type FileSinkSuite struct {
+	suite.Suite
+	path string
+}
+
+func TestFileSinkSuite(t *testing.T) {
+	suite.Run(t, new(FileSinkSuite))
+}
+
+func (s *FileSinkSuite) SetupTest() {
+	s.path = filepath.Join(s.T().TempDir(), "events.log")
+}
+
+func (s *FileSinkSuite) TestSendAppendsLines() {
+	sink, err := events.OpenFileSink(s.path)
+	s.Require().NoError(err)
+	s.NoError(sink.Send(context.Background(), events.Event{Key: "a", Type: events.ThreadCreated, ThreadID: "0"}))
+	s.NoError(sink.Close())
+
+	// reopening appends to what is there
+	sink, err = events.OpenFileSink(s.path)
+	s.Require().NoError(err)
+	s.NoError(sink.Send(context.Background(), events.Event{Key: "b", Type: events.ThreadDeleted, ThreadID: "0"}))
+	s.NoError(sink.Close())
+
+	f, err := os.Open(s.path)
+	s.Require().NoError(err)
+	defer f.Close()
+
+	keys := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event events.Event
+		s.NoError(json.Unmarshal(scanner.Bytes(), &event))
+		keys = append(keys, event.Key)
+	}
+	s.Equal([]string{"a", "b"}, keys)
+}
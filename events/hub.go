@@ -0,0 +1,229 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// Event types published for thread changes.
+const (
+	ThreadCreated = "created"
+	ThreadEdited  = "edited"
+	ThreadDeleted = "deleted"
+	ReplyCreated  = "reply_created"
+)
+
+const (
+	DefaultBufferSize  = 64
+	DefaultHistorySize = 1000
+)
+
+// Reasons a subscription ended without being closed by its owner.
+var (
+	ErrSlowSubscriber = errors.New("subscriber fell behind")
+	ErrHubClosed      = errors.New("hub closed")
+)
+
+// Event is a change published on a Hub. ID is set by the hub and grows by
+// one per event. Key identifies the change itself, an event sent twice
+// keeps its key.
+type Event struct {
+	ID       uint64      `json:"id"`
+	Key      string      `json:"key,omitempty"`
+	Type     string      `json:"type"`
+	ThreadID string      `json:"thread_id"`
+	Data     interface{} `json:"data,omitempty"`
+}
+
+// Hub fans events out to subscribers. Each subscriber has its own buffer, a
+// subscriber whose buffer is full when an event is published is evicted
+// instead of slowing down the publisher. The last events are kept so a
+// subscriber can resume after the last event it saw.
+type Hub struct {
+	mu          sync.Mutex
+	lastID      uint64
+	history     []Event
+	historySize int
+	// keys holds the keys of the events in history
+	keys        map[string]struct{}
+	bufferSize  int
+	subscribers map[*Subscription]struct{}
+	closed      bool
+}
+
+type HubOption func(*Hub)
+
+// WithBufferSize sets how many events a subscriber may lag behind before it
+// is evicted.
+func WithBufferSize(size int) HubOption {
+	return func(h *Hub) {
+		h.bufferSize = size
+	}
+}
+
+// WithHistorySize sets how many past events are kept for resuming.
+func WithHistorySize(size int) HubOption {
+	return func(h *Hub) {
+		h.historySize = size
+	}
+}
+
+func NewHub(opts ...HubOption) *Hub {
+	h := &Hub{
+		historySize: DefaultHistorySize,
+		bufferSize:  DefaultBufferSize,
+		keys:        make(map[string]struct{}),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Send numbers event and delivers it to every subscriber, unless an event
+// with the same key is still in the history, so a change relayed twice
+// reaches the subscribers once.
+func (h *Hub) Send(ctx context.Context, event Event) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, seen := h.keys[event.Key]; seen {
+		return nil
+	}
+	h.publish(event)
+	return nil
+}
+
+// publish must be called with h.mu held.
+func (h *Hub) publish(event Event) {
+	h.lastID++
+	event.ID = h.lastID
+
+	h.history = append(h.history, event)
+	if event.Key != "" {
+		h.keys[event.Key] = struct{}{}
+	}
+	if len(h.history) > h.historySize {
+		dropped := len(h.history) - h.historySize
+		for _, old := range h.history[:dropped] {
+			delete(h.keys, old.Key)
+		}
+		// append moves the events kept to a new array once this one is
+		// full, the dropped ones are not copied on every event
+		h.history = h.history[dropped:]
+	}
+
+	for sub := range h.subscribers {
+		select {
+		case sub.c <- event:
+		default:
+			h.evict(sub, ErrSlowSubscriber)
+		}
+	}
+}
+
+// Subscribe starts receiving the events published from now on, its
+// Backlog is empty.
+func (h *Hub) Subscribe() *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(h.lastID)
+}
+
+// Resume subscribes like Subscribe and returns in Backlog the events kept
+// that came after lastID. A lastID ahead of the hub was handed out before a
+// restart, it cannot be placed in the history and resumes from now.
+func (h *Hub) Resume(lastID uint64) *Subscription {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.subscribe(min(lastID, h.lastID))
+}
+
+// subscribe must be called with h.mu held. The subscription of a closed hub
+// only gets the backlog.
+func (h *Hub) subscribe(lastID uint64) *Subscription {
+	sub := &Subscription{
+		hub:     h,
+		c:       make(chan Event, h.bufferSize),
+		Backlog: []Event{},
+	}
+	for _, event := range h.history {
+		if event.ID > lastID {
+			sub.Backlog = append(sub.Backlog, event)
+		}
+	}
+	if h.closed {
+		sub.err = ErrHubClosed
+		close(sub.c)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Close ends every subscription so the streams reading them finish, it is
+// safe to call more than once.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.closed = true
+	for sub := range h.subscribers {
+		h.evict(sub, ErrHubClosed)
+	}
+}
+
+// evict must be called with h.mu held.
+func (h *Hub) evict(sub *Subscription, err error) {
+	if _, ok := h.subscribers[sub]; !ok {
+		return
+	}
+	delete(h.subscribers, sub)
+	sub.err = err
+	close(sub.c)
+}
+
+// Subscribers returns how many subscriptions are open.
+func (h *Hub) Subscribers() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return len(h.subscribers)
+}
+
+// Subscription receives the events of a Hub until it is closed or evicted.
+type Subscription struct {
+	hub *Hub
+	c   chan Event
+	err error
+	// Backlog holds the events published before Resume that came after the
+	// requested last event
+	Backlog []Event
+}
+
+// C delivers the events, it is closed when the subscription is closed or
+// evicted for falling behind.
+func (s *Subscription) C() <-chan Event {
+	return s.c
+}
+
+// Close stops the subscription, it is safe to call more than once.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	s.hub.evict(s, nil)
+}
+
+// Err tells why C was closed, it is nil while the subscription is open or
+// once it was closed by Close.
+func (s *Subscription) Err() error {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+
+	return s.err
+}
@@ -0,0 +1,178 @@
+package events_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/events"
+)
+
+type HubSuite struct {
+	suite.Suite
+	hub *events.Hub
+}
+
+func TestHubSuite(t *testing.T) {
+	suite.Run(t, new(HubSuite))
+}
+
+func (s *HubSuite) SetupTest() {
+	s.hub = events.NewHub(events.WithBufferSize(2), events.WithHistorySize(3))
+}
+
+func (s *HubSuite) publish(n int) {
+	for i := 0; i < n; i++ {
+		s.Require().NoError(s.hub.Send(context.Background(), events.Event{Type: events.ThreadCreated, ThreadID: "0"}))
+	}
+}
+
+func (s *HubSuite) ids(list []events.Event) []uint64 {
+	ids := []uint64{}
+	for _, event := range list {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func (s *HubSuite) TestSendDeliversInOrder() {
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+	s.Empty(sub.Backlog)
+
+	s.publish(2)
+	s.Equal(uint64(1), (<-sub.C()).ID)
+	s.Equal(uint64(2), (<-sub.C()).ID)
+}
+
+func (s *HubSuite) TestSubscribeStartsFromNow() {
+	s.publish(2)
+
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+	s.Empty(sub.Backlog)
+
+	s.publish(1)
+	s.Equal(uint64(3), (<-sub.C()).ID)
+}
+
+func (s *HubSuite) TestResumeAfterLastID() {
+	s.publish(4)
+
+	// only the last three events are kept
+	sub := s.hub.Resume(0)
+	s.Equal([]uint64{2, 3, 4}, s.ids(sub.Backlog))
+	sub.Close()
+
+	sub = s.hub.Resume(3)
+	s.Equal([]uint64{4}, s.ids(sub.Backlog))
+	sub.Close()
+
+	// ids ahead of the hub come from before a restart, none of the events
+	// kept can be told to follow them
+	sub = s.hub.Resume(40)
+	s.Empty(sub.Backlog)
+	s.publish(1)
+	s.Equal(uint64(5), (<-sub.C()).ID)
+	sub.Close()
+}
+
+func (s *HubSuite) TestSlowSubscriberIsEvicted() {
+	slow := s.hub.Subscribe()
+	fast := s.hub.Subscribe()
+	defer fast.Close()
+
+	for i := 0; i < 3; i++ {
+		s.publish(1)
+		<-fast.C()
+	}
+
+	s.Equal(1, s.hub.Subscribers())
+	s.Len(slow.C(), 2)
+	<-slow.C()
+	<-slow.C()
+	_, ok := <-slow.C()
+	s.False(ok)
+	s.ErrorIs(slow.Err(), events.ErrSlowSubscriber)
+	s.NoError(fast.Err())
+
+	// closing an evicted subscription does nothing
+	slow.Close()
+	s.Equal(1, s.hub.Subscribers())
+}
+
+func (s *HubSuite) TestCloseIsIdempotent() {
+	sub := s.hub.Subscribe()
+	sub.Close()
+	sub.Close()
+
+	s.Equal(0, s.hub.Subscribers())
+	_, ok := <-sub.C()
+	s.False(ok)
+	s.NoError(sub.Err())
+
+	s.publish(1)
+}
+
+func (s *HubSuite) TestHubCloseEndsSubscriptions() {
+	sub := s.hub.Subscribe()
+	s.publish(1)
+
+	s.hub.Close()
+	s.hub.Close()
+	s.Equal(0, s.hub.Subscribers())
+	// the events already delivered are still read
+	s.Equal(uint64(1), (<-sub.C()).ID)
+	_, ok := <-sub.C()
+	s.False(ok)
+	s.ErrorIs(sub.Err(), events.ErrHubClosed)
+
+	late := s.hub.Resume(0)
+	s.Equal([]uint64{1}, s.ids(late.Backlog))
+	_, ok = <-late.C()
+	s.False(ok)
+	s.ErrorIs(late.Err(), events.ErrHubClosed)
+	late.Close()
+}
+
+func (s *HubSuite) TestSendDropsRepeatedKeys() {
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+
+	s.NoError(s.hub.Send(context.Background(), events.Event{Key: "a", Type: events.ThreadCreated, ThreadID: "0"}))
+	s.NoError(s.hub.Send(context.Background(), events.Event{Key: "a", Type: events.ThreadCreated, ThreadID: "0"}))
+	s.NoError(s.hub.Send(context.Background(), events.Event{Key: "b", Type: events.ThreadDeleted, ThreadID: "0"}))
+
+	event := <-sub.C()
+	s.Equal(uint64(1), event.ID)
+	s.Equal("a", event.Key)
+	event = <-sub.C()
+	s.Equal(uint64(2), event.ID)
+	s.Equal("b", event.Key)
+}
+
+func (s *HubSuite) TestSendForgetsKeysOutOfHistory() {
+	sub := s.hub.Subscribe()
+	defer sub.Close()
+
+	send := func(key string) {
+		s.Require().NoError(s.hub.Send(context.Background(), events.Event{Key: key, Type: events.ThreadCreated, ThreadID: "0"}))
+	}
+	send("a")
+	send("b")
+	<-sub.C()
+	<-sub.C()
+	send("c")
+	send("d")
+	<-sub.C()
+	<-sub.C()
+
+	// a left the history of three events, it is sent again, d is still kept
+	send("a")
+	send("d")
+	event := <-sub.C()
+	s.Equal(uint64(5), event.ID)
+	s.Equal("a", event.Key)
+	s.Empty(sub.C())
+}
@@ -0,0 +1,45 @@
+package httphandler
+
+import (
+	"context"
+	"gofiber-api/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HttpAuthHandlerRepo interface {
+	Login(ctx context.Context, username string, password string) (auth.Token, error)
+}
+
+type LoginRequestType struct {
+	Username string `json:"username" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+type AuthHandler struct {
+	HttpAuthHandlerRepo
+}
+
+func NewAuthHandler(authService HttpAuthHandlerRepo) *AuthHandler {
+	return &AuthHandler{
+		HttpAuthHandlerRepo: authService,
+	}
+}
+
+func (ah *AuthHandler) Login(c *fiber.Ctx) error {
+	loginRequest := new(LoginRequestType)
+	if err := parseBody(c, loginRequest); err != nil {
+		return err
+	}
+
+	token, err := ah.HttpAuthHandlerRepo.Login(c.UserContext(), loginRequest.Username, loginRequest.Password)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success login",
+		Data:    token,
+	})
+}
@@ -0,0 +1,133 @@
+package httphandler
+
+import (
+	"gofiber-api/auth"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReplyRequestType struct {
+	Content string `json:"content" validate:"required"`
+}
+
+// CreateReplyRequestType answers the thread, or the reply ParentID when it
+// is set.
+type CreateReplyRequestType struct {
+	Content  string `json:"content" validate:"required"`
+	ParentID string `json:"parent_id"`
+}
+
+type ReplyTreeRequestType struct {
+	Depth int `query:"depth" validate:"omitempty,min=1"`
+}
+
+func (th *ThreadHandler) GetReplies(c *fiber.Ctx) error {
+	replies, err := th.Replies(c.UserContext(), param(c, "id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get replies",
+		Data:    replies,
+	})
+}
+
+func (th *ThreadHandler) GetReply(c *fiber.Ctx) error {
+	reply, err := th.Reply(c.UserContext(), param(c, "id"), param(c, "replyId"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get reply",
+		Data:    reply,
+	})
+}
+
+// GetReplyTree returns the replies of the thread as nested JSON, or only
+// the subtree below the replyId param when it is given.
+func (th *ThreadHandler) GetReplyTree(c *fiber.Ctx) error {
+	treeRequest := new(ReplyTreeRequestType)
+	if err := parseQuery(c, treeRequest); err != nil {
+		return err
+	}
+
+	tree, err := th.ReplyTree(c.UserContext(), param(c, "id"), param(c, "replyId"), treeRequest.Depth)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get reply tree",
+		Data:    tree,
+	})
+}
+
+func (th *ThreadHandler) CreateReply(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	replyRequest := new(CreateReplyRequestType)
+	if err := parseBody(c, replyRequest); err != nil {
+		return err
+	}
+
+	reply, err := th.PostReply(c.UserContext(), identity, param(c, "id"), replyRequest.ParentID, replyRequest.Content)
+	if err != nil {
+		return err
+	}
+
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + reply.ID)
+	return c.Status(fiber.StatusCreated).JSON(ResponseType{
+		Status:  fiber.StatusCreated,
+		Message: "success create reply",
+		Data:    reply,
+	})
+}
+
+func (th *ThreadHandler) EditReply(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	replyRequest := new(ReplyRequestType)
+	if err := parseBody(c, replyRequest); err != nil {
+		return err
+	}
+
+	reply, err := th.UpdateReply(c.UserContext(), identity, param(c, "id"), param(c, "replyId"), replyRequest.Content)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success edit reply",
+		Data:    reply,
+	})
+}
+
+func (th *ThreadHandler) DeleteReply(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	if err := th.RemoveReply(c.UserContext(), identity, param(c, "id"), param(c, "replyId")); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success delete reply",
+		Data:    nil,
+	})
+}
@@ -0,0 +1,188 @@
+package httphandler
+
+import (
+	"context"
+	"errors"
+	"gofiber-api/events"
+	repo "gofiber-api/repository"
+	"time"
+
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	// DefaultPingInterval is how often a socket is pinged, a client that
+	// does not answer within two intervals is disconnected.
+	DefaultPingInterval = 30 * time.Second
+
+	socketWriteWait = 10 * time.Second
+	// socketCloseWait is how long a closing socket waits for the client to
+	// acknowledge the close.
+	socketCloseWait = time.Second
+)
+
+type HttpSocketThreadRepo interface {
+	Get(ctx context.Context, id string) (repo.Thread, error)
+}
+
+type SocketHandler struct {
+	HttpStreamHandlerRepo
+	threads      HttpSocketThreadRepo
+	pingInterval time.Duration
+	socket       fiber.Handler
+}
+
+type SocketOption func(*SocketHandler)
+
+// WithPingInterval sets how often sockets are pinged.
+func WithPingInterval(interval time.Duration) SocketOption {
+	return func(sh *SocketHandler) {
+		sh.pingInterval = interval
+	}
+}
+
+func NewSocketHandler(hub HttpStreamHandlerRepo, threads HttpSocketThreadRepo, opts ...SocketOption) *SocketHandler {
+	sh := &SocketHandler{
+		HttpStreamHandlerRepo: hub,
+		threads:               threads,
+		pingInterval:          DefaultPingInterval,
+	}
+	for _, opt := range opts {
+		opt(sh)
+	}
+	sh.socket = websocket.New(sh.serveThread)
+	return sh
+}
+
+// UpgradeThreadSocket only lets websocket upgrades of existing threads
+// through, so a missing thread is answered like any other request.
+func (sh *SocketHandler) UpgradeThreadSocket(c *fiber.Ctx) error {
+	if !websocket.IsWebSocketUpgrade(c) {
+		return fiber.ErrUpgradeRequired
+	}
+
+	lastID, resume, err := lastEventID(c)
+	if err != nil {
+		return err
+	}
+	if _, err := sh.threads.Get(c.UserContext(), param(c, "id")); err != nil {
+		return err
+	}
+
+	if resume {
+		c.Locals("last_event_id", lastID)
+	}
+	return c.Next()
+}
+
+// ThreadSocket sends the changes of one thread and its new replies as JSON
+// events. The socket is closed once the thread is deleted.
+func (sh *SocketHandler) ThreadSocket(c *fiber.Ctx) error {
+	return sh.socket(c)
+}
+
+func (sh *SocketHandler) serveThread(conn *websocket.Conn) {
+	id := conn.Params("id")
+
+	var sub *events.Subscription
+	if lastID, resume := conn.Locals("last_event_id").(uint64); resume {
+		sub = sh.Resume(lastID)
+	} else {
+		sub = sh.Subscribe()
+	}
+	defer sub.Close()
+
+	// the reader answers pings, notices the pongs and the close of the
+	// client, nothing else is expected from it
+	done := make(chan struct{})
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * sh.pingInterval))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * sh.pingInterval))
+	})
+	go func() {
+		defer close(done)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	defer func() {
+		conn.Close()
+		<-done
+	}()
+
+	// the thread may have been deleted between the upgrade and the
+	// subscription, its event would then be missed
+	if _, err := sh.threads.Get(context.Background(), id); err != nil {
+		if errors.Is(err, repo.ErrNotFound) {
+			closeSocket(conn, done, websocket.CloseNormalClosure, "thread deleted")
+		} else {
+			closeSocket(conn, done, websocket.CloseInternalServerErr, "thread unavailable")
+		}
+		return
+	}
+
+	send := func(event events.Event) bool {
+		if event.ThreadID != id {
+			return true
+		}
+		conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+		if err := conn.WriteJSON(event); err != nil {
+			return false
+		}
+		if event.Type == events.ThreadDeleted {
+			closeSocket(conn, done, websocket.CloseNormalClosure, "thread deleted")
+			return false
+		}
+		return true
+	}
+
+	// only a client resuming after an event gets the ones it missed
+	for _, event := range sub.Backlog {
+		if !send(event) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(sh.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case event, ok := <-sub.C():
+			if !ok {
+				if errors.Is(sub.Err(), events.ErrHubClosed) {
+					closeSocket(conn, done, websocket.CloseGoingAway, "shutting down")
+				} else {
+					closeSocket(conn, done, websocket.CloseTryAgainLater, "too slow")
+				}
+				return
+			}
+			if !send(event) {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait)); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// closeSocket starts the closing handshake and waits for the client to
+// answer it.
+func closeSocket(conn *websocket.Conn, done <-chan struct{}, code int, text string) {
+	err := conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(socketWriteWait))
+	if err != nil {
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(socketCloseWait):
+	}
+}
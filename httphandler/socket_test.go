@@ -0,0 +1,138 @@
+package httphandler_test
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v2"
+
+	"gofiber-api/auth"
+	"gofiber-api/events"
+	handler "gofiber-api/httphandler"
+	repo "gofiber-api/repository"
+	"gofiber-api/router"
+)
+
+// dial opens the socket of the thread, it is closed when the test ends.
+func (s *StreamHttpHandlerSuite) dial(id string) (*websocket.Conn, *http.Response, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial("ws://"+s.ln.Addr().String()+"/api/threads/"+id+"/ws", nil)
+	if err == nil {
+		s.T().Cleanup(func() { conn.Close() })
+	}
+	return conn, resp, err
+}
+
+func (s *StreamHttpHandlerSuite) TestThreadSocket() {
+	ctx := context.Background()
+	author := auth.Identity{Username: "ramamimu", Role: auth.RoleUser}
+
+	thread, err := s.service.Add(ctx, author.Username, "hello world", nil)
+	s.Require().NoError(err)
+
+	conn, _, err := s.dial(thread.ID)
+	s.Require().NoError(err)
+	s.Eventually(func() bool { return s.hub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	// changes of other threads are not sent
+	other, err := s.service.Add(ctx, author.Username, "something else", nil)
+	s.Require().NoError(err)
+	s.Require().NoError(s.service.Delete(ctx, author, other.ID))
+
+	reply, err := s.service.PostReply(ctx, author, thread.ID, "", "first!")
+	s.Require().NoError(err)
+	_, err = s.service.Edit(ctx, author, thread.ID, "hello again", nil, 0)
+	s.Require().NoError(err)
+	s.Require().NoError(s.service.Delete(ctx, author, thread.ID))
+
+	var event events.Event
+	s.Require().NoError(conn.ReadJSON(&event))
+	s.Equal(events.ReplyCreated, event.Type)
+	s.Equal(thread.ID, event.ThreadID)
+	s.Equal(reply.ID, event.Data.(map[string]interface{})["id"])
+
+	s.Require().NoError(conn.ReadJSON(&event))
+	s.Equal(events.ThreadEdited, event.Type)
+	s.Equal("hello again", event.Data.(map[string]interface{})["content"])
+
+	s.Require().NoError(conn.ReadJSON(&event))
+	s.Equal(events.ThreadDeleted, event.Type)
+
+	// the server closes the socket once the thread is gone
+	_, _, err = conn.ReadMessage()
+	s.True(websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+	s.Eventually(func() bool { return s.hub.Subscribers() == 0 }, time.Second, time.Millisecond)
+}
+
+func (s *StreamHttpHandlerSuite) TestThreadSocketPings() {
+	thread, err := s.service.Add(context.Background(), "ramamimu", "hello world", nil)
+	s.Require().NoError(err)
+
+	conn, _, err := s.dial(thread.ID)
+	s.Require().NoError(err)
+
+	pinged := make(chan struct{}, 1)
+	conn.SetPingHandler(func(data string) error {
+		select {
+		case pinged <- struct{}{}:
+		default:
+		}
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	select {
+	case <-pinged:
+	case <-time.After(time.Second):
+		s.Fail("the socket was not pinged")
+	}
+}
+
+func (s *StreamHttpHandlerSuite) TestThreadSocketOfMissingThread() {
+	_, resp, err := s.dial("missing")
+	s.ErrorIs(err, websocket.ErrBadHandshake)
+	s.Require().NotNil(resp)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+}
+
+// vanishingThreads finds a thread only once, as if it were deleted right
+// after the upgrade checked it.
+type vanishingThreads struct {
+	found atomic.Bool
+}
+
+func (v *vanishingThreads) Get(ctx context.Context, id string) (repo.Thread, error) {
+	if v.found.CompareAndSwap(false, true) {
+		return repo.Thread{ID: id}, nil
+	}
+	return repo.Thread{}, repo.ErrThreadNotFound
+}
+
+func (s *StreamHttpHandlerSuite) TestThreadSocketOfThreadDeletedDuringUpgrade() {
+	hub := events.NewHub()
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	router.NewSocketRoute(handler.NewSocketHandler(hub, &vanishingThreads{})).Route(app.Group("/api"))
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	go app.Listener(ln)
+	defer app.Shutdown()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws://"+ln.Addr().String()+"/api/threads/0/ws", nil)
+	s.Require().NoError(err)
+	defer conn.Close()
+
+	// the deleted event was published before the socket subscribed
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _, err = conn.ReadMessage()
+	s.True(websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+	s.Eventually(func() bool { return hub.Subscribers() == 0 }, time.Second, time.Millisecond)
+}
@@ -0,0 +1,140 @@
+package httphandler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"gofiber-api/events"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/valyala/fasthttp"
+)
+
+// DefaultKeepAlive is how often an idle stream sends a comment, so proxies
+// do not close it and a gone client is noticed.
+const DefaultKeepAlive = 15 * time.Second
+
+type HttpStreamHandlerRepo interface {
+	Subscribe() *events.Subscription
+	Resume(lastID uint64) *events.Subscription
+}
+
+type StreamHandler struct {
+	HttpStreamHandlerRepo
+	keepAlive time.Duration
+}
+
+type StreamOption func(*StreamHandler)
+
+// WithKeepAlive sets how often an idle stream sends a keep-alive comment.
+func WithKeepAlive(interval time.Duration) StreamOption {
+	return func(sh *StreamHandler) {
+		sh.keepAlive = interval
+	}
+}
+
+func NewStreamHandler(hub HttpStreamHandlerRepo, opts ...StreamOption) *StreamHandler {
+	sh := &StreamHandler{
+		HttpStreamHandlerRepo: hub,
+		keepAlive:             DefaultKeepAlive,
+	}
+	for _, opt := range opts {
+		opt(sh)
+	}
+	return sh
+}
+
+// StreamThreads sends the thread changes as Server-Sent Events. A client
+// reconnecting with Last-Event-ID first receives the events it missed, a
+// new one only the changes from now on.
+func (sh *StreamHandler) StreamThreads(c *fiber.Ctx) error {
+	sub, err := sh.subscribe(c)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	c.Set(fiber.HeaderConnection, "keep-alive")
+	c.Set("X-Accel-Buffering", "no")
+	c.Status(fiber.StatusOK).Context().SetBodyStreamWriter(fasthttp.StreamWriter(func(w *bufio.Writer) {
+		defer sub.Close()
+
+		for _, event := range sub.Backlog {
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return
+		}
+
+		ticker := time.NewTicker(sh.keepAlive)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case event, ok := <-sub.C():
+				// evicted for falling behind or shutting down, the client
+				// reconnects and resumes from its last event
+				if !ok {
+					return
+				}
+				if err := writeEvent(w, event); err != nil {
+					return
+				}
+			case <-ticker.C:
+				if _, err := w.WriteString(": keep-alive\n\n"); err != nil {
+					return
+				}
+			}
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}))
+	return nil
+}
+
+// subscribe resumes after the event the client last saw, or starts from
+// now when it saw none.
+func (sh *StreamHandler) subscribe(c *fiber.Ctx) (*events.Subscription, error) {
+	lastID, resume, err := lastEventID(c)
+	if err != nil {
+		return nil, err
+	}
+	if !resume {
+		return sh.Subscribe(), nil
+	}
+	return sh.Resume(lastID), nil
+}
+
+// lastEventID reads the Last-Event-ID header browsers send on reconnect, or
+// the last_event_id query for the first connection. resume is false when
+// the client gave neither.
+func lastEventID(c *fiber.Ctx) (id uint64, resume bool, err error) {
+	value := c.Get("Last-Event-ID")
+	if value == "" {
+		value = c.Query("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+
+	id, err = strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, false, fiber.NewError(fiber.StatusBadRequest, "Last-Event-ID must be a number")
+	}
+	return id, true, nil
+}
+
+func writeEvent(w *bufio.Writer, event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
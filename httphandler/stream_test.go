@@ -0,0 +1,168 @@
+package httphandler_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/auth"
+	"gofiber-api/events"
+	handler "gofiber-api/httphandler"
+	midware "gofiber-api/middleware"
+	repo "gofiber-api/repository"
+	"gofiber-api/router"
+	service "gofiber-api/service"
+)
+
+type StreamHttpHandlerSuite struct {
+	suite.Suite
+	app     *fiber.App
+	ln      net.Listener
+	hub     *events.Hub
+	service *service.ThreadService
+	Db      repo.Db
+	// stop ends the relay of the outbox to the hub
+	stop context.CancelFunc
+}
+
+func TestStreamHttpHandlerSuite(t *testing.T) {
+	suite.Run(t, new(StreamHttpHandlerSuite))
+}
+
+func (s *StreamHttpHandlerSuite) SetupSuite() {
+	s.app = fiber.New(fiber.Config{DisableStartupMessage: true})
+	s.Db = repo.Db{}
+	s.Db.Init()
+
+	errorHandlerMiddleware := midware.NewErrorHandlerMiddleware(s.app)
+	errorHandlerMiddleware.Bind()
+
+	s.hub = events.NewHub()
+	relay := service.NewRelay(&s.Db, service.WithSink(s.hub), service.WithRelayInterval(10*time.Millisecond))
+	var ctx context.Context
+	ctx, s.stop = context.WithCancel(context.Background())
+	go relay.Run(ctx)
+	s.service = service.NewThread(&s.Db, service.WithNotifier(relay))
+
+	streamRouter := router.NewStreamRoute(handler.NewStreamHandler(s.hub, handler.WithKeepAlive(10*time.Millisecond)))
+	threadRouter := router.NewThreadRoute(handler.NewThreadHandler(s.service))
+	socketRouter := router.NewSocketRoute(handler.NewSocketHandler(s.hub, s.service, handler.WithPingInterval(10*time.Millisecond)))
+
+	api := s.app.Group("/api")
+	streamRouter.Route(api)
+	threadRouter.Route(api)
+	socketRouter.Route(api)
+
+	// streams and sockets never end, they are read from a real connection
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.ln = ln
+	go s.app.Listener(ln)
+}
+
+// SetupTest waits for the connections of the previous test to be noticed
+// as closed, tests count the subscribers of the hub.
+func (s *StreamHttpHandlerSuite) SetupTest() {
+	s.Eventually(func() bool { return s.hub.Subscribers() == 0 }, time.Second, time.Millisecond)
+}
+
+func (s *StreamHttpHandlerSuite) TearDownSuite() {
+	s.stop()
+	s.Require().NoError(s.app.Shutdown())
+}
+
+// open connects to the stream, the body is closed when the test ends.
+func (s *StreamHttpHandlerSuite) open(lastEventID string) *bufio.Reader {
+	req, err := http.NewRequest(http.MethodGet, "http://"+s.ln.Addr().String()+"/api/threads/stream", nil)
+	s.Require().NoError(err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	s.Require().NoError(err)
+	s.T().Cleanup(func() { resp.Body.Close() })
+
+	s.Require().Equal(fiber.StatusOK, resp.StatusCode)
+	s.Equal("text/event-stream", resp.Header.Get(fiber.HeaderContentType))
+	return bufio.NewReader(resp.Body)
+}
+
+// next reads one event off the stream, skipping keep-alive comments.
+func (s *StreamHttpHandlerSuite) next(r *bufio.Reader) (string, events.Event) {
+	var eventType string
+	var event events.Event
+	for {
+		line, err := r.ReadString('\n')
+		s.Require().NoError(err)
+		line = strings.TrimSuffix(line, "\n")
+
+		switch {
+		case line == "" && eventType != "":
+			return eventType, event
+		case strings.HasPrefix(line, "event: "):
+			eventType = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			s.Require().NoError(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event))
+		}
+	}
+}
+
+func (s *StreamHttpHandlerSuite) TestStreamThreadChanges() {
+	stream := s.open("")
+	s.Eventually(func() bool { return s.hub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	thread, err := s.service.Add(context.Background(), "ramamimu", "hello world", nil)
+	s.Require().NoError(err)
+
+	eventType, event := s.next(stream)
+	s.Equal(events.ThreadCreated, eventType)
+	s.Equal(thread.ID, event.ThreadID)
+	s.Equal("hello world", event.Data.(map[string]interface{})["content"])
+
+	// resuming after the created event only replays what came later
+	_, err = s.service.Edit(context.Background(), auth.Identity{Username: thread.Author, Role: auth.RoleUser}, thread.ID, "hello again", nil, 0)
+	s.Require().NoError(err)
+
+	eventType, edited := s.next(stream)
+	s.Equal(events.ThreadEdited, eventType)
+	s.Equal(event.ID+1, edited.ID)
+
+	resumed := s.open(strconv.FormatUint(event.ID, 10))
+	eventType, replayed := s.next(resumed)
+	s.Equal(events.ThreadEdited, eventType)
+	s.Equal(edited.ID, replayed.ID)
+}
+
+func (s *StreamHttpHandlerSuite) TestStreamDoesNotReplayToNewClients() {
+	for i := 0; i < 3; i++ {
+		s.Require().NoError(s.hub.Send(context.Background(), events.Event{Type: events.ThreadEdited, ThreadID: "old"}))
+	}
+
+	stream := s.open("")
+	s.Eventually(func() bool { return s.hub.Subscribers() == 1 }, time.Second, time.Millisecond)
+
+	thread, err := s.service.Add(context.Background(), "ramamimu", "hello world", nil)
+	s.Require().NoError(err)
+
+	eventType, event := s.next(stream)
+	s.Equal(events.ThreadCreated, eventType)
+	s.Equal(thread.ID, event.ThreadID)
+}
+
+func (s *StreamHttpHandlerSuite) TestStreamRejectsMalformedLastEventID() {
+	req := httptest.NewRequest(http.MethodGet, "/api/threads/stream?last_event_id=abc", nil)
+	resp, err := s.app.Test(req)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusBadRequest, resp.StatusCode)
+}
@@ -2,33 +2,97 @@ package httphandler
 
 import (
 	"context"
+	"gofiber-api/auth"
 	repo "gofiber-api/repository"
-	"sort"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
 type HttpThreadHandlerRepo interface {
-	GetAll(ctx context.Context) []repo.Thread
-	Add(ctx context.Context, author string, content string) error
-	Edit(ctx context.Context, id string, content string) error
-	Delete(ctx context.Context, id string) error
+	List(ctx context.Context, opts repo.ListOptions) (repo.ThreadPage, error)
+	Get(ctx context.Context, id string) (repo.Thread, error)
+	Search(ctx context.Context, query string, limit int) ([]repo.SearchResult, error)
+	Add(ctx context.Context, author string, content string, tags []string) (repo.Thread, error)
+	Edit(ctx context.Context, actor auth.Identity, id string, content string, tags []string, version int) (repo.Thread, error)
+	Delete(ctx context.Context, actor auth.Identity, id string) error
+	Revisions(ctx context.Context, id string) ([]repo.Revision, error)
+	Revision(ctx context.Context, id string, number int) (repo.Revision, error)
+	RestoreRevision(ctx context.Context, actor auth.Identity, id string, number int, version int) (repo.Thread, error)
+	Trash(ctx context.Context, actor auth.Identity) ([]repo.Thread, error)
+	Restore(ctx context.Context, actor auth.Identity, id string) (repo.Thread, error)
+	Replies(ctx context.Context, threadID string) ([]repo.Reply, error)
+	Reply(ctx context.Context, threadID string, replyID string) (repo.Reply, error)
+	ReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]repo.ReplyNode, error)
+	PostReply(ctx context.Context, actor auth.Identity, threadID string, parentID string, content string) (repo.Reply, error)
+	UpdateReply(ctx context.Context, actor auth.Identity, threadID string, replyID string, content string) (repo.Reply, error)
+	RemoveReply(ctx context.Context, actor auth.Identity, threadID string, replyID string) error
+	React(ctx context.Context, actor auth.Identity, id string, kind string) (repo.Thread, error)
+	Unreact(ctx context.Context, actor auth.Identity, id string, kind string) (repo.Thread, error)
+	Tags(ctx context.Context) ([]repo.TagCount, error)
 }
 
 type ResponseType struct {
-	Status  int         `json:"status"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Status     int         `json:"status"`
+	Message    string      `json:"message"`
+	Data       interface{} `json:"data"`
+	NextCursor string      `json:"next_cursor,omitempty"`
 }
 
+type ListThreadsRequestType struct {
+	Limit         int    `query:"limit" validate:"omitempty,min=1,max=100"`
+	Cursor        string `query:"cursor"`
+	Author        string `query:"author"`
+	Tag           string `query:"tag"`
+	Edited        *bool  `query:"edited"`
+	CreatedAfter  string `query:"created_after" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	CreatedBefore string `query:"created_before" validate:"omitempty,datetime=2006-01-02T15:04:05Z07:00"`
+	Sort          string `query:"sort" validate:"omitempty,oneof=created last_update author top"`
+	Order         string `query:"order" validate:"omitempty,oneof=asc desc"`
+}
+
+type SearchThreadsRequestType struct {
+	Query string `query:"q" validate:"required"`
+	Limit int    `query:"limit" validate:"omitempty,min=1,max=100"`
+}
+
+// options converts a validated request into repository list options.
+func (r *ListThreadsRequestType) options() repo.ListOptions {
+	opts := repo.ListOptions{
+		Limit:  r.Limit,
+		Cursor: r.Cursor,
+		Author: r.Author,
+		Tag:    r.Tag,
+		Edited: r.Edited,
+		Sort:   r.Sort,
+		Order:  r.Order,
+	}
+	opts.CreatedAfter, _ = time.Parse(time.RFC3339, r.CreatedAfter)
+	opts.CreatedBefore, _ = time.Parse(time.RFC3339, r.CreatedBefore)
+	return opts
+}
+
+// CreateThreadRequestType carries no author, threads are posted as the
+// authenticated caller.
 type CreateThreadRequestType struct {
-	Content string `json:"content" validate:"required"`
-	Author  string `json:"author" validate:"required"`
+	Content string   `json:"content" validate:"required"`
+	Tags    []string `json:"tags" validate:"max=5,dive,tag"`
+}
+
+type ReactionRequestType struct {
+	Kind string `validate:"required,oneof=like upvote downvote heart laugh sad"`
 }
 
+// EditThreadRequestType keeps the content when it is empty and the tags
+// when they are missing, an empty list removes them.
 type EditThreadRequestType struct {
-	NewContent string `json:"content"`
+	NewContent string   `json:"content"`
+	Tags       []string `json:"tags" validate:"omitempty,max=5,dive,tag"`
 }
 
 type ThreadHandler struct {
@@ -42,108 +106,186 @@ func NewThreadHandler(threadService HttpThreadHandlerRepo) *ThreadHandler {
 }
 
 func (th *ThreadHandler) GetAllThreads(c *fiber.Ctx) error {
-	threads := th.GetAll(context.Background())
-	sort.SliceStable(threads, func(i, j int) bool {
-		return threads[i].LastUpdate.After(threads[j].LastUpdate)
+	listRequest := new(ListThreadsRequestType)
+	if err := parseQuery(c, listRequest); err != nil {
+		return err
+	}
+
+	page, err := th.List(c.UserContext(), listRequest.options())
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:     fiber.StatusOK,
+		Message:    "success get threads",
+		Data:       page.Threads,
+		NextCursor: page.NextCursor,
 	})
+}
+
+func (th *ThreadHandler) GetThread(c *fiber.Ctx) error {
+	thread, err := th.Get(c.UserContext(), param(c, "id"))
+	if err != nil {
+		return err
+	}
 
+	c.Set(fiber.HeaderETag, etag(thread))
 	return c.Status(fiber.StatusOK).JSON(ResponseType{
 		Status:  fiber.StatusOK,
-		Message: "success get threads",
-		Data:    threads,
+		Message: "success get thread",
+		Data:    thread,
+	})
+}
+
+func (th *ThreadHandler) SearchThreads(c *fiber.Ctx) error {
+	searchRequest := new(SearchThreadsRequestType)
+	if err := parseQuery(c, searchRequest); err != nil {
+		return err
+	}
+
+	results, err := th.Search(c.UserContext(), searchRequest.Query, searchRequest.Limit)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success search threads",
+		Data:    results,
+	})
+}
+
+var validate = newValidator()
+
+// tagPattern is what a tag may look like, case is ignored.
+var tagPattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9-]{0,31}$`)
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("tag", func(fl validator.FieldLevel) bool {
+		return tagPattern.MatchString(fl.Field().String())
 	})
+	return v
+}
+
+func validationErrors(err error) []string {
+	var messages []string
+	for _, err := range err.(validator.ValidationErrors) {
+		messages = append(messages, err.Field()+" is "+err.Tag())
+	}
+	return messages
+}
+
+// parseQuery fills request from the query string and validates it.
+func parseQuery(c *fiber.Ctx, request interface{}) error {
+	if err := c.QueryParser(request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return validateRequest(request)
 }
 
-var validate = validator.New()
+// param returns a copy of a route param, fiber reuses the memory of the
+// request once the handler returns and the stores keep some of them.
+func param(c *fiber.Ctx, key string) string {
+	return utils.CopyString(c.Params(key))
+}
+
+// parseBody fills request from the body and validates it.
+func parseBody(c *fiber.Ctx, request interface{}) error {
+	if err := c.BodyParser(request); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return validateRequest(request)
+}
+
+func validateRequest(request interface{}) error {
+	if err := validate.Struct(request); err != nil {
+		return repo.NewValidationError(validationErrors(err)...)
+	}
+	return nil
+}
 
 func (th *ThreadHandler) CreateThread(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
 	threadRequest := new(CreateThreadRequestType)
+	if err := parseBody(c, threadRequest); err != nil {
+		return err
+	}
 
-	if err := c.BodyParser(threadRequest); err != nil {
-		return c.JSON(
-			ResponseType{
-				Status:  c.Response().StatusCode(),
-				Message: c.Response().String(),
-				Data: []string{
-					err.Error(),
-				},
-			})
-	}
-
-	// empty body request validation
-	// best practice is put all of the parameter in domain
-	// Validate the parsed body using the validator
-	if err := validate.Struct(threadRequest); err != nil {
-		// If validation fails, return a bad request error with details
-		return c.Status(fiber.StatusBadRequest).JSON(ResponseType{
-			Status:  fiber.StatusBadRequest,
-			Message: "Validation failed",
-			Data: func(err error) []string {
-				var errors []string
-				for _, err := range err.(validator.ValidationErrors) {
-					errors = append(errors, err.Field()+" is "+err.Tag())
-				}
-				return errors
-			}(err),
-		})
-	}
-
-	if err := th.Add(context.Background(), threadRequest.Author, threadRequest.Content); err != nil {
-		return c.Status(fiber.StatusInternalServerError).JSON(ResponseType{
-			Status:  c.Response().StatusCode(),
-			Message: c.Response().String(),
-			Data: []string{
-				err.Error(),
-			},
-		})
+	thread, err := th.Add(c.UserContext(), identity.Username, threadRequest.Content, threadRequest.Tags)
+	if err != nil {
+		return err
 	}
 
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + thread.ID)
 	return c.Status(fiber.StatusCreated).JSON(ResponseType{
 		Status:  fiber.StatusCreated,
 		Message: "success create thread",
-		Data:    nil,
+		Data:    thread,
 	})
 }
 
 func (th *ThreadHandler) EditThread(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
 	threadRequest := new(EditThreadRequestType)
+	if err := parseBody(c, threadRequest); err != nil {
+		return err
+	}
 
-	if err := c.BodyParser(threadRequest); err != nil {
-		return c.Status(fiber.StatusBadRequest).JSON(ResponseType{
-			Status:  fiber.StatusBadRequest,
-			Message: "bad request",
-			Data: []string{
-				err.Error(),
-			},
-		})
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
 	}
 
-	if err := th.Edit(context.Background(), c.Params("id"), threadRequest.NewContent); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(ResponseType{
-			Status:  fiber.StatusNotFound,
-			Message: "not found",
-			Data: []string{
-				err.Error(),
-			},
-		})
+	thread, err := th.Edit(c.UserContext(), identity, param(c, "id"), threadRequest.NewContent, threadRequest.Tags, version)
+	if err != nil {
+		return err
 	}
 
+	c.Set(fiber.HeaderETag, etag(thread))
 	return c.Status(fiber.StatusOK).JSON(ResponseType{
 		Status:  fiber.StatusOK,
 		Message: "success edit thread",
-		Data:    nil,
+		Data:    thread,
 	})
 }
 
+func etag(thread repo.Thread) string {
+	return `"` + strconv.Itoa(thread.Version) + `"`
+}
+
+// ifMatchVersion returns the version required by the If-Match header, zero
+// when the edit is unconditional.
+func ifMatchVersion(c *fiber.Ctx) (int, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(strings.Trim(header, `"`))
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) || !strings.HasSuffix(header, `"`) {
+		return 0, repo.ErrVersionMismatch
+	}
+	return version, nil
+}
+
 func (th *ThreadHandler) DeleteThread(c *fiber.Ctx) error {
-	if err := th.Delete(context.Background(), c.Params("id")); err != nil {
-		return c.Status(fiber.StatusNotFound).JSON(ResponseType{
-			Status:  fiber.StatusNotFound,
-			Message: "not found",
-			Data: []string{
-				err.Error(),
-			},
-		})
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	if err := th.Delete(c.UserContext(), identity, param(c, "id")); err != nil {
+		return err
 	}
 
 	return c.Status(fiber.StatusOK).JSON(ResponseType{
@@ -152,3 +294,144 @@ func (th *ThreadHandler) DeleteThread(c *fiber.Ctx) error {
 		Data:    nil,
 	})
 }
+
+func (th *ThreadHandler) GetThreadRevisions(c *fiber.Ctx) error {
+	revisions, err := th.Revisions(c.UserContext(), param(c, "id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get revisions",
+		Data:    revisions,
+	})
+}
+
+func (th *ThreadHandler) GetThreadRevision(c *fiber.Ctx) error {
+	number, err := c.ParamsInt("rev")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "revision must be a number")
+	}
+
+	revision, err := th.Revision(c.UserContext(), param(c, "id"), number)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get revision",
+		Data:    revision,
+	})
+}
+
+func (th *ThreadHandler) RestoreThreadRevision(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	number, err := c.ParamsInt("rev")
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "revision must be a number")
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	thread, err := th.RestoreRevision(c.UserContext(), identity, param(c, "id"), number, version)
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(thread))
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success restore revision",
+		Data:    thread,
+	})
+}
+
+func (th *ThreadHandler) GetTrash(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	threads, err := th.Trash(c.UserContext(), identity)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get trash",
+		Data:    threads,
+	})
+}
+
+func (th *ThreadHandler) RestoreThread(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	thread, err := th.Restore(c.UserContext(), identity, param(c, "id"))
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, etag(thread))
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success restore thread",
+		Data:    thread,
+	})
+}
+
+func (th *ThreadHandler) AddReaction(c *fiber.Ctx) error {
+	return th.react(c, th.React, "success add reaction")
+}
+
+func (th *ThreadHandler) RemoveReaction(c *fiber.Ctx) error {
+	return th.react(c, th.Unreact, "success remove reaction")
+}
+
+func (th *ThreadHandler) react(c *fiber.Ctx, react func(context.Context, auth.Identity, string, string) (repo.Thread, error), message string) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	reactionRequest := &ReactionRequestType{Kind: param(c, "kind")}
+	if err := validateRequest(reactionRequest); err != nil {
+		return err
+	}
+
+	thread, err := react(c.UserContext(), identity, param(c, "id"), reactionRequest.Kind)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: message,
+		Data:    thread,
+	})
+}
+
+func (th *ThreadHandler) GetTags(c *fiber.Ctx) error {
+	tags, err := th.Tags(c.UserContext())
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get tags",
+		Data:    tags,
+	})
+}
@@ -7,12 +7,16 @@ import (
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/stretchr/testify/suite"
 
+	"gofiber-api/auth"
 	handler "gofiber-api/httphandler"
+	midware "gofiber-api/middleware"
 	repo "gofiber-api/repository"
 	"gofiber-api/router"
 	service "gofiber-api/service"
@@ -20,8 +24,11 @@ import (
 
 type ThreadHttpHandlerSuite struct {
 	suite.Suite
-	app *fiber.App
-	Db  repo.Db
+	app        *fiber.App
+	Db         repo.Db
+	signer     *auth.Signer
+	dispatcher *service.Dispatcher
+	relay      *service.Relay
 }
 
 func TestThreadHttpHandlerSuite(t *testing.T) {
@@ -37,12 +44,52 @@ func (s *ThreadHttpHandlerSuite) SetupSuite() {
 
 	s.Db = repo.Db{}
 
-	threadService := service.NewThread(&s.Db)
+	users := auth.NewUserStore()
+	s.Require().NoError(users.AddUser("ramamimu", "the-password", auth.RoleUser))
+	s.signer = auth.NewSigner([]byte("the-secret"), time.Hour)
+
+	errorHandlerMiddleware := midware.NewErrorHandlerMiddleware(s.app)
+	errorHandlerMiddleware.Bind()
+	authMiddleware := midware.NewAuthMiddleware(s.app, s.signer)
+	authMiddleware.Bind()
+
+	authService := service.NewAuth(users, s.signer)
+	authHandler := handler.NewAuthHandler(authService)
+	authRouter := router.NewAuthRoute(authHandler)
+
+	// janitors may clean up but not rewrite threads of others
+	policy := auth.NewPolicy(map[string][]string{
+		auth.RoleModerator: {auth.PermEditAnyThread, auth.PermDeleteAnyThread, auth.PermManageTrash},
+		"janitor":          {auth.PermDeleteAnyThread},
+		auth.RoleAdmin:     {auth.PermManageWebhooks},
+	})
+
+	// retries are due right away, the tests run the dispatcher by hand
+	s.dispatcher = service.NewDispatcher(&s.Db, service.WithMaxAttempts(2), service.WithRetryBackoff(0, 0))
+	s.relay = service.NewRelay(&s.Db, service.WithSink(s.dispatcher))
+	webhookService := service.NewWebhook(&s.Db, policy)
+	webhookHandler := handler.NewWebhookHandler(webhookService)
+	webhookRouter := router.NewWebhookRoute(webhookHandler)
+
+	threadService := service.NewThread(&s.Db,
+		service.WithPolicy(policy),
+		service.WithMaxReplyDepth(2),
+		service.WithNotifier(s.relay),
+	)
 	threadHandler := handler.NewThreadHandler(threadService)
 	threadRouter := router.NewThreadRoute(threadHandler)
 
 	api := s.app.Group("/api")
+	authRouter.Route(api)
 	threadRouter.Route(api)
+	webhookRouter.Route(api)
+}
+
+// authorize signs req as username holding role.
+func (s *ThreadHttpHandlerSuite) authorize(req *http.Request, username string, role string) {
+	token, err := s.signer.Issue(auth.Identity{Username: username, Role: role})
+	s.Require().NoError(err)
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token.Token)
 }
 
 func (s *ThreadHttpHandlerSuite) SetupTest() {
@@ -67,9 +114,10 @@ func (s *ThreadHttpHandlerSuite) TestPing() {
 }
 
 func (s *ThreadHttpHandlerSuite) TestCreateNewThread() {
-	reqBody := `{"content":"hello world","author":"ramamimu"}`
+	reqBody := `{"content":"hello world","author":"someone-else"}`
 	req := httptest.NewRequest(http.MethodPost, "/api/threads", bytes.NewReader([]byte(reqBody)))
 	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req, "ramamimu", auth.RoleUser)
 
 	resp, err := s.app.Test(req)
 	s.Nil(err)
@@ -80,14 +128,26 @@ func (s *ThreadHttpHandlerSuite) TestCreateNewThread() {
 	s.NotNil(bodyString)
 	s.NotEmpty(bodyString)
 
+	s.Equal("/api/threads/0", resp.Header.Get(fiber.HeaderLocation))
+
 	var ResponseType handler.ResponseType
 	err = json.Unmarshal(bodyString, &ResponseType)
 	s.Nil(err)
 	s.Equal(fiber.StatusCreated, ResponseType.Status)
 	s.Equal("success create thread", ResponseType.Message)
-	s.Nil(ResponseType.Data)
 
-	thread, err := s.Db.GetThreadByID("0")
+	createdData, err := json.Marshal(ResponseType.Data)
+	s.NoError(err)
+
+	var created repo.Thread
+	err = json.Unmarshal(createdData, &created)
+	s.NoError(err)
+	s.Equal("0", created.ID)
+	s.Equal("hello world", created.Content)
+	s.Equal("ramamimu", created.Author)
+	s.NotEmpty(created.Created)
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
 	s.Nil(err)
 	s.NotEmpty(thread)
 	s.Equal("hello world", thread.Content)
@@ -142,6 +202,147 @@ func (s *ThreadHttpHandlerSuite) TestGetThreads() {
 	s.Equal("the content 1", thread1.Content)
 }
 
+func (s *ThreadHttpHandlerSuite) TestGetThreadsPagination() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+	s.Db.AddThread(context.Background(), "the-author-2", "the content 2")
+	s.Db.AddThread(context.Background(), "the-author-3", "the content 3")
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads?limit=2", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var firstPage handler.ResponseType
+	err = json.Unmarshal(bodyString, &firstPage)
+	s.NoError(err)
+	s.Len(firstPage.Data, 2)
+	s.NotEmpty(firstPage.NextCursor)
+
+	req = httptest.NewRequest(fiber.MethodGet, "/api/threads?limit=2&cursor="+firstPage.NextCursor, nil)
+	resp, err = s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err = io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var secondPage handler.ResponseType
+	err = json.Unmarshal(bodyString, &secondPage)
+	s.NoError(err)
+	s.Empty(secondPage.NextCursor)
+
+	threadsData, err := json.Marshal(secondPage.Data)
+	s.NoError(err)
+
+	var threads []repo.Thread
+	err = json.Unmarshal(threadsData, &threads)
+	s.NoError(err)
+	s.Equal(1, len(threads))
+	s.Equal("the-author-1", threads[0].Author)
+}
+
+func (s *ThreadHttpHandlerSuite) TestGetThreadsBadPagination() {
+	for _, query := range []string{"limit=-1", "limit=101", "limit=abc", "cursor=garbage"} {
+		req := httptest.NewRequest(fiber.MethodGet, "/api/threads?"+query, nil)
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		s.Equal(fiber.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func (s *ThreadHttpHandlerSuite) TestGetThreadsFilterAndSort() {
+	s.Db.AddThread(context.Background(), "bob", "the content 0")
+	s.Db.AddThread(context.Background(), "alice", "the content 1")
+	s.Db.AddThread(context.Background(), "alice", "the content 2")
+	s.Db.EditThread(context.Background(), "1", repo.ThreadEdit{Content: "the edited content"})
+
+	list := func(query string) []repo.Thread {
+		req := httptest.NewRequest(fiber.MethodGet, "/api/threads?"+query, nil)
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		s.Equal(fiber.StatusOK, resp.StatusCode, query)
+
+		bodyString, err := io.ReadAll(resp.Body)
+		s.Nil(err)
+
+		var positiveResponse handler.ResponseType
+		err = json.Unmarshal(bodyString, &positiveResponse)
+		s.NoError(err)
+
+		threadsData, err := json.Marshal(positiveResponse.Data)
+		s.NoError(err)
+
+		var threads []repo.Thread
+		err = json.Unmarshal(threadsData, &threads)
+		s.NoError(err)
+		return threads
+	}
+
+	threads := list("author=alice&sort=created&order=asc")
+	s.Equal(2, len(threads))
+	s.Equal("1", threads[0].ID)
+	s.Equal("2", threads[1].ID)
+
+	threads = list("edited=true")
+	s.Equal(1, len(threads))
+	s.Equal("the edited content", threads[0].Content)
+
+	threads = list("sort=author&order=desc")
+	s.Equal(3, len(threads))
+	s.Equal("bob", threads[0].Author)
+
+	threads = list("created_after=" + time.Now().Add(-time.Hour).UTC().Format(time.RFC3339) + "&edited=false")
+	s.Equal(2, len(threads))
+}
+
+func (s *ThreadHttpHandlerSuite) TestGetThreadsBadFilters() {
+	for _, query := range []string{"sort=content", "order=up", "edited=maybe", "created_after=yesterday"} {
+		req := httptest.NewRequest(fiber.MethodGet, "/api/threads?"+query, nil)
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		s.Equal(fiber.StatusBadRequest, resp.StatusCode, query)
+	}
+}
+
+func (s *ThreadHttpHandlerSuite) TestSearchThreads() {
+	long := strings.Repeat("filler words ", 20) + "the <needle> is here " + strings.Repeat("more filler ", 20)
+	s.Db.AddThread(context.Background(), "the-author-1", long)
+	s.Db.AddThread(context.Background(), "the-author-2", "no match in this one")
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/search?q=needle", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal("success search threads", positiveResponse.Message)
+
+	resultsData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var results []repo.SearchResult
+	err = json.Unmarshal(resultsData, &results)
+	s.NoError(err)
+	s.Equal(1, len(results))
+	s.Equal("0", results[0].Thread.ID)
+	s.Contains(results[0].Snippet, "&lt;<mark>needle</mark>&gt;")
+	s.True(strings.HasPrefix(results[0].Snippet, "…"))
+	s.True(strings.HasSuffix(results[0].Snippet, "…"))
+
+	req = httptest.NewRequest(fiber.MethodGet, "/api/threads/search", nil)
+	resp, err = s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusBadRequest, resp.StatusCode)
+}
+
 func (s *ThreadHttpHandlerSuite) TestEditThread() {
 	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
 	s.Db.AddThread(context.Background(), "the-author-2", "the content 2")
@@ -150,6 +351,7 @@ func (s *ThreadHttpHandlerSuite) TestEditThread() {
 	reqBody := `{"content":"some new contents"}`
 	req := httptest.NewRequest(fiber.MethodPut, "/api/threads/1", bytes.NewReader([]byte(reqBody)))
 	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req, "the-author-2", auth.RoleUser)
 
 	resp, err := s.app.Test(req)
 	s.NoError(err)
@@ -174,6 +376,7 @@ func (s *ThreadHttpHandlerSuite) TestDeleteThread() {
 	s.Equal(1, len(threads))
 
 	req := httptest.NewRequest(fiber.MethodDelete, "/api/threads/0", nil)
+	s.authorize(req, "the-author-1", auth.RoleUser)
 	resp, err := s.app.Test(req)
 	s.NoError(err)
 	s.Equal(fiber.StatusOK, resp.StatusCode)
@@ -189,3 +392,614 @@ func (s *ThreadHttpHandlerSuite) TestDeleteThread() {
 	s.Equal(fiber.StatusOK, positiveResponse.Status)
 	s.Equal("success delete thread", positiveResponse.Message)
 }
+
+func (s *ThreadHttpHandlerSuite) TestGetThread() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+	s.Db.AddThread(context.Background(), "the-author-2", "the content 2")
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/1", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+	s.NotEmpty(bodyString)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, positiveResponse.Status)
+	s.Equal("success get thread", positiveResponse.Message)
+
+	threadData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var thread repo.Thread
+	err = json.Unmarshal(threadData, &thread)
+	s.NoError(err)
+	s.Equal("1", thread.ID)
+	s.Equal("the-author-2", thread.Author)
+	s.Equal("the content 2", thread.Content)
+}
+
+func (s *ThreadHttpHandlerSuite) TestGetThreadNotFound() {
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/42", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var negativeResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &negativeResponse)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, negativeResponse.Status)
+	s.Equal("not found", negativeResponse.Message)
+}
+
+func (s *ThreadHttpHandlerSuite) TestCreateThreadValidation() {
+	reqBody := `{"author":"ramamimu"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/threads", bytes.NewReader([]byte(reqBody)))
+	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req, "ramamimu", auth.RoleUser)
+
+	resp, err := s.app.Test(req)
+	s.Nil(err)
+	s.Equal(fiber.StatusBadRequest, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var negativeResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &negativeResponse)
+	s.NoError(err)
+	s.Equal(fiber.StatusBadRequest, negativeResponse.Status)
+	s.Equal("Validation failed", negativeResponse.Message)
+	s.Equal([]interface{}{"Content is required"}, negativeResponse.Data)
+}
+
+func (s *ThreadHttpHandlerSuite) TestEditAndDeleteMissingThread() {
+	reqBody := `{"content":"some new contents"}`
+	req := httptest.NewRequest(fiber.MethodPut, "/api/threads/42", bytes.NewReader([]byte(reqBody)))
+	req.Header.Set("Content-Type", "application/json")
+	s.authorize(req, "ramamimu", auth.RoleUser)
+
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+
+	req = httptest.NewRequest(fiber.MethodDelete, "/api/threads/42", nil)
+	s.authorize(req, "ramamimu", auth.RoleUser)
+	resp, err = s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var negativeResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &negativeResponse)
+	s.NoError(err)
+	s.Equal("not found", negativeResponse.Message)
+	s.Equal([]interface{}{"thread not found"}, negativeResponse.Data)
+}
+
+func (s *ThreadHttpHandlerSuite) TestCreateThreadUnauthorized() {
+	reqBody := `{"content":"hello world","author":"ramamimu"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/threads", bytes.NewReader([]byte(reqBody)))
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusUnauthorized, resp.StatusCode)
+	s.Equal("Bearer", resp.Header.Get(fiber.HeaderWWWAuthenticate))
+	s.Empty(s.Db.GetThreadsEntity())
+
+	for _, header := range []string{"Bearer garbage", "Basic cmFtYW1pbXU6cHc=", "Bearer " + strings.Repeat("a", 10) + ".sig"} {
+		req = httptest.NewRequest(http.MethodPost, "/api/threads", bytes.NewReader([]byte(reqBody)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(fiber.HeaderAuthorization, header)
+
+		resp, err = s.app.Test(req)
+		s.NoError(err)
+		s.Equal(fiber.StatusUnauthorized, resp.StatusCode, header)
+	}
+	s.Empty(s.Db.GetThreadsEntity())
+}
+
+func (s *ThreadHttpHandlerSuite) TestLogin() {
+	reqBody := `{"username":"ramamimu","password":"the-password"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader([]byte(reqBody)))
+	req.Header.Set("Content-Type", "application/json")
+
+	// bcrypt at its default cost can outlast the one second Test waits
+	resp, err := s.app.Test(req, -1)
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal("success login", positiveResponse.Message)
+
+	tokenData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var token auth.Token
+	err = json.Unmarshal(tokenData, &token)
+	s.NoError(err)
+	s.NotEmpty(token.Token)
+	s.True(token.ExpiresAt.After(time.Now()))
+
+	// the issued token posts as its owner
+	reqBody = `{"content":"hello world"}`
+	req = httptest.NewRequest(http.MethodPost, "/api/threads", bytes.NewReader([]byte(reqBody)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer "+token.Token)
+
+	resp, err = s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusCreated, resp.StatusCode)
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal("ramamimu", thread.Author)
+}
+
+func (s *ThreadHttpHandlerSuite) TestLoginInvalidCredentials() {
+	for _, reqBody := range []string{
+		`{"username":"ramamimu","password":"wrong"}`,
+		`{"username":"nobody","password":"the-password"}`,
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader([]byte(reqBody)))
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.app.Test(req, -1)
+		s.Require().NoError(err)
+		s.Equal(fiber.StatusUnauthorized, resp.StatusCode, reqBody)
+	}
+}
+
+func (s *ThreadHttpHandlerSuite) TestEditAndDeleteOwnership() {
+	s.Db.AddThread(context.Background(), "the-author", "the content")
+
+	edit := func(username string, role string) int {
+		reqBody := `{"content":"edited by ` + username + `"}`
+		req := httptest.NewRequest(fiber.MethodPut, "/api/threads/0", bytes.NewReader([]byte(reqBody)))
+		req.Header.Set("Content-Type", "application/json")
+		if username != "" {
+			s.authorize(req, username, role)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp.StatusCode
+	}
+	remove := func(username string, role string) int {
+		req := httptest.NewRequest(fiber.MethodDelete, "/api/threads/0", nil)
+		if username != "" {
+			s.authorize(req, username, role)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp.StatusCode
+	}
+
+	s.Equal(fiber.StatusUnauthorized, edit("", ""))
+	s.Equal(fiber.StatusForbidden, edit("someone-else", auth.RoleUser))
+	s.Equal(fiber.StatusForbidden, edit("someone-else", "janitor"))
+	// admin is not part of the configured policy
+	s.Equal(fiber.StatusForbidden, edit("someone-else", auth.RoleAdmin))
+	s.Equal(fiber.StatusOK, edit("the-author", auth.RoleUser))
+	s.Equal(fiber.StatusOK, edit("the-moderator", auth.RoleModerator))
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal("edited by the-moderator", thread.Content)
+
+	s.Equal(fiber.StatusUnauthorized, remove("", ""))
+	s.Equal(fiber.StatusForbidden, remove("someone-else", auth.RoleUser))
+	s.Equal(fiber.StatusOK, remove("the-janitor", "janitor"))
+	s.Empty(s.Db.GetThreadsEntity())
+}
+
+func (s *ThreadHttpHandlerSuite) TestEditThreadIfMatch() {
+	s.Db.AddThread(context.Background(), "the-author", "the content")
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/0", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+	etag := resp.Header.Get(fiber.HeaderETag)
+	s.Equal(`"1"`, etag)
+
+	edit := func(ifMatch string) *http.Response {
+		reqBody := `{"content":"some new contents"}`
+		req := httptest.NewRequest(fiber.MethodPut, "/api/threads/0", bytes.NewReader([]byte(reqBody)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+		s.authorize(req, "the-author", auth.RoleUser)
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	resp = edit(etag)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+	s.Equal(`"2"`, resp.Header.Get(fiber.HeaderETag))
+
+	// the first etag is stale now
+	resp = edit(etag)
+	s.Equal(fiber.StatusPreconditionFailed, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var negativeResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &negativeResponse)
+	s.NoError(err)
+	s.Equal(fiber.StatusPreconditionFailed, negativeResponse.Status)
+	s.Equal("precondition failed", negativeResponse.Message)
+
+	s.Equal(fiber.StatusPreconditionFailed, edit("2").StatusCode)
+	s.Equal(fiber.StatusPreconditionFailed, edit(`W/"2"`).StatusCode)
+	s.Equal(fiber.StatusOK, edit(`"2"`).StatusCode)
+	s.Equal(fiber.StatusOK, edit("*").StatusCode)
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(4, thread.Version)
+}
+
+func (s *ThreadHttpHandlerSuite) TestThreadRevisions() {
+	s.Db.AddThread(context.Background(), "the-author", "the first content")
+	s.Db.EditThread(context.Background(), "0", repo.ThreadEdit{Content: "the second content", Editor: "the-author"})
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/0/revisions", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal("success get revisions", positiveResponse.Message)
+
+	revisionsData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var revisions []repo.Revision
+	err = json.Unmarshal(revisionsData, &revisions)
+	s.NoError(err)
+	s.Equal(2, len(revisions))
+	s.Equal("the first content", revisions[0].Content)
+
+	req = httptest.NewRequest(fiber.MethodGet, "/api/threads/0/revisions/1", nil)
+	resp, err = s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	for path, status := range map[string]int{
+		"/api/threads/0/revisions/3":   fiber.StatusNotFound,
+		"/api/threads/0/revisions/one": fiber.StatusBadRequest,
+		"/api/threads/9/revisions":     fiber.StatusNotFound,
+	} {
+		req = httptest.NewRequest(fiber.MethodGet, path, nil)
+		resp, err = s.app.Test(req)
+		s.NoError(err)
+		s.Equal(status, resp.StatusCode, path)
+	}
+}
+
+func (s *ThreadHttpHandlerSuite) TestRestoreThreadRevision() {
+	s.Db.AddThread(context.Background(), "the-author", "the first content")
+	s.Db.EditThread(context.Background(), "0", repo.ThreadEdit{Content: "the vandalized content", Editor: "the-author"})
+
+	restore := func(username string, ifMatch string) *http.Response {
+		req := httptest.NewRequest(fiber.MethodPost, "/api/threads/0/revisions/1/restore", nil)
+		req.Header.Set(fiber.HeaderIfMatch, ifMatch)
+		if username != "" {
+			s.authorize(req, username, auth.RoleUser)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	s.Equal(fiber.StatusUnauthorized, restore("", "").StatusCode)
+	s.Equal(fiber.StatusForbidden, restore("someone-else", "").StatusCode)
+	s.Equal(fiber.StatusPreconditionFailed, restore("the-author", `"1"`).StatusCode)
+
+	resp := restore("the-author", `"2"`)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+	s.Equal(`"3"`, resp.Header.Get(fiber.HeaderETag))
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal("the first content", thread.Content)
+
+	// restoring is an edit of its own, the history keeps growing
+	revisions, err := s.Db.GetRevisions(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(3, len(revisions))
+	s.Equal("the-author", revisions[2].Editor)
+}
+
+func (s *ThreadHttpHandlerSuite) TestTrashAndRestoreThread() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+	s.NoError(s.Db.DeleteThread(context.Background(), "0"))
+
+	send := func(method string, path string, username string, role string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		if username != "" {
+			s.authorize(req, username, role)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	// authors cannot dig their threads back up, only moderators can
+	s.Equal(fiber.StatusUnauthorized, send(fiber.MethodGet, "/api/trash", "", "").StatusCode)
+	s.Equal(fiber.StatusForbidden, send(fiber.MethodGet, "/api/trash", "the-author-1", auth.RoleUser).StatusCode)
+	s.Equal(fiber.StatusForbidden, send(fiber.MethodPost, "/api/threads/0/restore", "the-author-1", auth.RoleUser).StatusCode)
+
+	resp := send(fiber.MethodGet, "/api/trash", "the-moderator", auth.RoleModerator)
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal("success get trash", positiveResponse.Message)
+
+	threadsData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var threads []repo.Thread
+	err = json.Unmarshal(threadsData, &threads)
+	s.NoError(err)
+	s.Equal(1, len(threads))
+	s.NotNil(threads[0].DeletedAt)
+
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodGet, "/api/threads/0", "", "").StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodPost, "/api/threads/0/restore", "the-moderator", auth.RoleModerator).StatusCode)
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodPost, "/api/threads/0/restore", "the-moderator", auth.RoleModerator).StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodGet, "/api/threads/0", "", "").StatusCode)
+}
+
+func (s *ThreadHttpHandlerSuite) TestReplies() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+
+	send := func(method string, path string, body string, username string, role string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		if username != "" {
+			s.authorize(req, username, role)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	s.Equal(fiber.StatusUnauthorized, send(fiber.MethodPost, "/api/threads/0/replies", `{"content":"the reply"}`, "", "").StatusCode)
+	s.Equal(fiber.StatusBadRequest, send(fiber.MethodPost, "/api/threads/0/replies", `{}`, "the-replier", auth.RoleUser).StatusCode)
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodPost, "/api/threads/9/replies", `{"content":"the reply"}`, "the-replier", auth.RoleUser).StatusCode)
+
+	resp := send(fiber.MethodPost, "/api/threads/0/replies", `{"content":"the reply"}`, "the-replier", auth.RoleUser)
+	s.Equal(fiber.StatusCreated, resp.StatusCode)
+	s.Equal("/api/threads/0/replies/0", resp.Header.Get(fiber.HeaderLocation))
+	s.Equal(fiber.StatusOK, send(fiber.MethodGet, "/api/threads/0/replies/0", "", "", "").StatusCode)
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodGet, "/api/threads/0/replies/9", "", "", "").StatusCode)
+
+	// only the author of the reply or a moderator may change it
+	s.Equal(fiber.StatusForbidden, send(fiber.MethodPut, "/api/threads/0/replies/0", `{"content":"the hijacked reply"}`, "the-author-1", auth.RoleUser).StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodPut, "/api/threads/0/replies/0", `{"content":"the edited reply"}`, "the-replier", auth.RoleUser).StatusCode)
+
+	resp = send(fiber.MethodGet, "/api/threads/0/replies", "", "", "")
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+	s.Equal("success get replies", positiveResponse.Message)
+
+	repliesData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var replies []repo.Reply
+	err = json.Unmarshal(repliesData, &replies)
+	s.NoError(err)
+	s.Equal(1, len(replies))
+	s.Equal("the edited reply", replies[0].Content)
+	s.Equal("the-replier", replies[0].Author)
+
+	thread, err := s.Db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, thread.ReplyCount)
+
+	s.Equal(fiber.StatusForbidden, send(fiber.MethodDelete, "/api/threads/0/replies/0", "", "the-author-1", auth.RoleUser).StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodDelete, "/api/threads/0/replies/0", "", "the-moderator", auth.RoleModerator).StatusCode)
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodDelete, "/api/threads/0/replies/0", "", "the-moderator", auth.RoleModerator).StatusCode)
+}
+
+func (s *ThreadHttpHandlerSuite) TestReplyTree() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+
+	post := func(body string) *http.Response {
+		req := httptest.NewRequest(fiber.MethodPost, "/api/threads/0/replies", strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		s.authorize(req, "the-replier", auth.RoleUser)
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	s.Equal(fiber.StatusCreated, post(`{"content":"the reply"}`).StatusCode)
+	s.Equal(fiber.StatusCreated, post(`{"content":"the answer","parent_id":"0"}`).StatusCode)
+	s.Equal(fiber.StatusNotFound, post(`{"content":"the orphan","parent_id":"9"}`).StatusCode)
+	// the suite allows two levels
+	s.Equal(fiber.StatusBadRequest, post(`{"content":"the third level","parent_id":"1"}`).StatusCode)
+
+	tree := func(path string) []repo.ReplyNode {
+		req := httptest.NewRequest(fiber.MethodGet, path, nil)
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		s.Equal(fiber.StatusOK, resp.StatusCode)
+
+		bodyString, err := io.ReadAll(resp.Body)
+		s.Nil(err)
+
+		var positiveResponse handler.ResponseType
+		err = json.Unmarshal(bodyString, &positiveResponse)
+		s.NoError(err)
+		s.Equal("success get reply tree", positiveResponse.Message)
+
+		treeData, err := json.Marshal(positiveResponse.Data)
+		s.NoError(err)
+
+		var nodes []repo.ReplyNode
+		err = json.Unmarshal(treeData, &nodes)
+		s.NoError(err)
+		return nodes
+	}
+
+	nodes := tree("/api/threads/0/replies/tree")
+	s.Equal(1, len(nodes))
+	s.Equal("the reply", nodes[0].Content)
+	s.Equal(1, len(nodes[0].Replies))
+	s.Equal("the answer", nodes[0].Replies[0].Content)
+	s.Equal("0", nodes[0].Replies[0].ParentID)
+
+	nodes = tree("/api/threads/0/replies/tree?depth=1")
+	s.Equal(1, len(nodes))
+	s.Empty(nodes[0].Replies)
+
+	nodes = tree("/api/threads/0/replies/1/tree")
+	s.Equal(1, len(nodes))
+	s.Equal("the answer", nodes[0].Content)
+
+	req := httptest.NewRequest(fiber.MethodGet, "/api/threads/0/replies/9/tree", nil)
+	resp, err := s.app.Test(req)
+	s.NoError(err)
+	s.Equal(fiber.StatusNotFound, resp.StatusCode)
+}
+
+func (s *ThreadHttpHandlerSuite) TestReactions() {
+	s.Db.AddThread(context.Background(), "the-author-1", "the content 1")
+	s.Db.AddThread(context.Background(), "the-author-2", "the content 2")
+
+	send := func(method string, path string, username string) *http.Response {
+		req := httptest.NewRequest(method, path, nil)
+		if username != "" {
+			s.authorize(req, username, auth.RoleUser)
+		}
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	s.Equal(fiber.StatusUnauthorized, send(fiber.MethodPut, "/api/threads/1/reactions/upvote", "").StatusCode)
+	s.Equal(fiber.StatusBadRequest, send(fiber.MethodPut, "/api/threads/1/reactions/shrug", "user-1").StatusCode)
+	s.Equal(fiber.StatusNotFound, send(fiber.MethodPut, "/api/threads/9/reactions/upvote", "user-1").StatusCode)
+
+	// adding the same reaction twice is idempotent
+	for i := 0; i < 2; i++ {
+		s.Equal(fiber.StatusOK, send(fiber.MethodPut, "/api/threads/0/reactions/upvote", "user-1").StatusCode)
+	}
+	s.Equal(fiber.StatusOK, send(fiber.MethodPut, "/api/threads/0/reactions/laugh", "user-2").StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodDelete, "/api/threads/0/reactions/laugh", "user-2").StatusCode)
+	s.Equal(fiber.StatusOK, send(fiber.MethodDelete, "/api/threads/0/reactions/laugh", "user-2").StatusCode)
+
+	resp := send(fiber.MethodGet, "/api/threads?sort=top", "")
+	s.Equal(fiber.StatusOK, resp.StatusCode)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Nil(err)
+
+	var positiveResponse handler.ResponseType
+	err = json.Unmarshal(bodyString, &positiveResponse)
+	s.NoError(err)
+
+	threadsData, err := json.Marshal(positiveResponse.Data)
+	s.NoError(err)
+
+	var threads []repo.Thread
+	err = json.Unmarshal(threadsData, &threads)
+	s.NoError(err)
+	s.Equal(2, len(threads))
+	s.Equal("0", threads[0].ID)
+	s.Equal(1, threads[0].Score)
+	s.Equal(map[string]int{repo.ReactionUpvote: 1}, threads[0].Reactions)
+	s.Equal(0, threads[1].Score)
+}
+
+func (s *ThreadHttpHandlerSuite) TestThreadTags() {
+	send := func(method string, path string, body string) *http.Response {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+		s.authorize(req, "the-author-1", auth.RoleUser)
+
+		resp, err := s.app.Test(req)
+		s.NoError(err)
+		return resp
+	}
+
+	s.Equal(fiber.StatusCreated, send(fiber.MethodPost, "/api/threads", `{"content":"the content 1","tags":["Go","web"]}`).StatusCode)
+	s.Equal(fiber.StatusCreated, send(fiber.MethodPost, "/api/threads", `{"content":"the content 2","tags":["go"]}`).StatusCode)
+	s.Equal(fiber.StatusBadRequest, send(fiber.MethodPost, "/api/threads", `{"content":"the content 3","tags":["not a tag"]}`).StatusCode)
+	s.Equal(fiber.StatusBadRequest, send(fiber.MethodPost, "/api/threads", `{"content":"the content 3","tags":["a","b","c","d","e","f"]}`).StatusCode)
+
+	data := func(resp *http.Response, out interface{}) {
+		s.Equal(fiber.StatusOK, resp.StatusCode)
+
+		bodyString, err := io.ReadAll(resp.Body)
+		s.Nil(err)
+
+		var positiveResponse handler.ResponseType
+		err = json.Unmarshal(bodyString, &positiveResponse)
+		s.NoError(err)
+
+		raw, err := json.Marshal(positiveResponse.Data)
+		s.NoError(err)
+		s.NoError(json.Unmarshal(raw, out))
+	}
+
+	var tags []repo.TagCount
+	data(send(fiber.MethodGet, "/api/tags", ""), &tags)
+	s.Equal([]repo.TagCount{{Tag: "go", Count: 2}, {Tag: "web", Count: 1}}, tags)
+
+	var threads []repo.Thread
+	data(send(fiber.MethodGet, "/api/threads?tag=web", ""), &threads)
+	s.Equal(1, len(threads))
+	s.Equal("0", threads[0].ID)
+
+	// tags can be edited alone
+	var thread repo.Thread
+	data(send(fiber.MethodPut, "/api/threads/1", `{"tags":["news"]}`), &thread)
+	s.Equal("the content 2", thread.Content)
+	s.Equal([]string{"news"}, thread.Tags)
+
+	data(send(fiber.MethodGet, "/api/threads?tag=go", ""), &threads)
+	s.Equal(1, len(threads))
+}
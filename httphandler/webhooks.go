@@ -0,0 +1,158 @@
+package httphandler
+
+import (
+	"context"
+	"gofiber-api/auth"
+	repo "gofiber-api/repository"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HttpWebhookHandlerRepo interface {
+	Webhooks(ctx context.Context, actor auth.Identity) ([]repo.Webhook, error)
+	Webhook(ctx context.Context, actor auth.Identity, id string) (repo.Webhook, error)
+	Register(ctx context.Context, actor auth.Identity, url string, events []string) (repo.Webhook, error)
+	Unregister(ctx context.Context, actor auth.Identity, id string) error
+	Deliveries(ctx context.Context, actor auth.Identity, webhookID string, status string) ([]repo.Delivery, error)
+	DeadLetters(ctx context.Context, actor auth.Identity) ([]repo.Delivery, error)
+}
+
+type CreateWebhookRequestType struct {
+	URL    string   `json:"url" validate:"required,http_url"`
+	Events []string `json:"events" validate:"required,min=1,dive,oneof=created edited deleted reply_created"`
+}
+
+type DeliveriesRequestType struct {
+	Status string `query:"status" validate:"omitempty,oneof=pending delivered dead"`
+}
+
+type WebhookHandler struct {
+	HttpWebhookHandlerRepo
+}
+
+func NewWebhookHandler(webhookService HttpWebhookHandlerRepo) *WebhookHandler {
+	return &WebhookHandler{
+		HttpWebhookHandlerRepo: webhookService,
+	}
+}
+
+func (wh *WebhookHandler) GetWebhooks(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	webhooks, err := wh.Webhooks(c.UserContext(), identity)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get webhooks",
+		Data:    webhooks,
+	})
+}
+
+func (wh *WebhookHandler) GetWebhook(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	webhook, err := wh.Webhook(c.UserContext(), identity, param(c, "id"))
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get webhook",
+		Data:    webhook,
+	})
+}
+
+// CreateWebhook answers with the secret of the webhook, it cannot be read
+// afterwards.
+func (wh *WebhookHandler) CreateWebhook(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	webhookRequest := new(CreateWebhookRequestType)
+	if err := parseBody(c, webhookRequest); err != nil {
+		return err
+	}
+
+	webhook, err := wh.Register(c.UserContext(), identity, webhookRequest.URL, webhookRequest.Events)
+	if err != nil {
+		return err
+	}
+
+	c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + webhook.ID)
+	return c.Status(fiber.StatusCreated).JSON(ResponseType{
+		Status:  fiber.StatusCreated,
+		Message: "success create webhook",
+		Data:    webhook,
+	})
+}
+
+func (wh *WebhookHandler) DeleteWebhook(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	if err := wh.Unregister(c.UserContext(), identity, param(c, "id")); err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success delete webhook",
+		Data:    nil,
+	})
+}
+
+func (wh *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	deliveriesRequest := new(DeliveriesRequestType)
+	if err := parseQuery(c, deliveriesRequest); err != nil {
+		return err
+	}
+
+	deliveries, err := wh.Deliveries(c.UserContext(), identity, param(c, "id"), deliveriesRequest.Status)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get deliveries",
+		Data:    deliveries,
+	})
+}
+
+func (wh *WebhookHandler) GetDeadLetters(c *fiber.Ctx) error {
+	identity, ok := auth.IdentityFrom(c)
+	if !ok {
+		return auth.ErrUnauthorized
+	}
+
+	deliveries, err := wh.DeadLetters(c.UserContext(), identity)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusOK).JSON(ResponseType{
+		Status:  fiber.StatusOK,
+		Message: "success get dead letters",
+		Data:    deliveries,
+	})
+}
@@ -0,0 +1,152 @@
+package httphandler_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"gofiber-api/auth"
+	handler "gofiber-api/httphandler"
+	repo "gofiber-api/repository"
+	service "gofiber-api/service"
+)
+
+type receivedHook struct {
+	header http.Header
+	body   []byte
+}
+
+// receiver records the webhooks it is sent and answers them with status.
+func (s *ThreadHttpHandlerSuite) receiver(status int) (*httptest.Server, chan receivedHook) {
+	received := make(chan receivedHook, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- receivedHook{header: r.Header, body: body}
+		w.WriteHeader(status)
+	}))
+	s.T().Cleanup(server.Close)
+	return server, received
+}
+
+func (s *ThreadHttpHandlerSuite) sendAs(username string, role string, method string, path string, body string) *http.Response {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	s.authorize(req, username, role)
+
+	resp, err := s.app.Test(req)
+	s.Require().NoError(err)
+	return resp
+}
+
+func (s *ThreadHttpHandlerSuite) responseData(resp *http.Response, status int, out interface{}) {
+	s.Require().Equal(status, resp.StatusCode)
+
+	var response handler.ResponseType
+	s.Require().NoError(json.NewDecoder(resp.Body).Decode(&response))
+	raw, err := json.Marshal(response.Data)
+	s.Require().NoError(err)
+	s.Require().NoError(json.Unmarshal(raw, out))
+}
+
+// dispatch relays the outbox to the dispatcher and attempts the deliveries
+// that are due.
+func (s *ThreadHttpHandlerSuite) dispatch() int {
+	_, err := s.relay.Drain(context.Background())
+	s.Require().NoError(err)
+	n, err := s.dispatcher.Dispatch(context.Background())
+	s.Require().NoError(err)
+	return n
+}
+
+func (s *ThreadHttpHandlerSuite) TestWebhooks() {
+	server, received := s.receiver(fiber.StatusNoContent)
+	register := `{"url":"` + server.URL + `/hook","events":["created","deleted"]}`
+
+	s.Equal(fiber.StatusForbidden, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodPost, "/api/webhooks", register).StatusCode)
+	s.Equal(fiber.StatusBadRequest, s.sendAs("root", auth.RoleAdmin, fiber.MethodPost, "/api/webhooks", `{"url":"ftp://example.com","events":["created"]}`).StatusCode)
+	s.Equal(fiber.StatusBadRequest, s.sendAs("root", auth.RoleAdmin, fiber.MethodPost, "/api/webhooks", `{"url":"http://example.com","events":["read"]}`).StatusCode)
+
+	var webhook repo.Webhook
+	resp := s.sendAs("root", auth.RoleAdmin, fiber.MethodPost, "/api/webhooks", register)
+	s.responseData(resp, fiber.StatusCreated, &webhook)
+	s.Equal("/api/webhooks/"+webhook.ID, resp.Header.Get(fiber.HeaderLocation))
+	s.NotEmpty(webhook.Secret)
+
+	// the secret is only shown once
+	var webhooks []repo.Webhook
+	s.responseData(s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks", ""), fiber.StatusOK, &webhooks)
+	s.Equal(1, len(webhooks))
+	s.Empty(webhooks[0].Secret)
+
+	s.Equal(fiber.StatusCreated, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodPost, "/api/threads", `{"content":"hello world"}`).StatusCode)
+	s.Equal(fiber.StatusOK, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodPut, "/api/threads/0", `{"content":"hello again"}`).StatusCode)
+	s.Equal(1, s.dispatch())
+
+	hook := <-received
+	s.Equal("created", hook.header.Get(service.HeaderWebhookEvent))
+	s.Equal(service.Sign(webhook.Secret, hook.body), hook.header.Get(service.HeaderWebhookSignature))
+	var payload service.WebhookPayload
+	s.NoError(json.Unmarshal(hook.body, &payload))
+	s.Equal("created", payload.Event)
+	s.Equal("0", payload.ThreadID)
+	s.Equal("hello world", payload.Data.(map[string]interface{})["content"])
+	s.NotEmpty(payload.Key)
+	// the relayed events leave the outbox
+	outbox, err := s.Db.GetOutbox(context.Background(), 0)
+	s.NoError(err)
+	s.Empty(outbox)
+
+	s.Equal(fiber.StatusOK, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodDelete, "/api/threads/0", "").StatusCode)
+	s.Equal(1, s.dispatch())
+	hook = <-received
+	s.Equal("deleted", hook.header.Get(service.HeaderWebhookEvent))
+	s.Equal(0, s.dispatch())
+
+	var deliveries []repo.Delivery
+	s.responseData(s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries", ""), fiber.StatusOK, &deliveries)
+	s.Equal(2, len(deliveries))
+	for _, delivery := range deliveries {
+		s.Equal(repo.DeliveryDelivered, delivery.Status)
+		s.Equal(1, delivery.Attempts)
+	}
+
+	s.Equal(fiber.StatusOK, s.sendAs("root", auth.RoleAdmin, fiber.MethodDelete, "/api/webhooks/"+webhook.ID, "").StatusCode)
+	s.Equal(fiber.StatusNotFound, s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/"+webhook.ID, "").StatusCode)
+	s.Equal(fiber.StatusNotFound, s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries", "").StatusCode)
+}
+
+func (s *ThreadHttpHandlerSuite) TestWebhookDeadLetters() {
+	server, received := s.receiver(fiber.StatusInternalServerError)
+
+	var webhook repo.Webhook
+	s.responseData(s.sendAs("root", auth.RoleAdmin, fiber.MethodPost, "/api/webhooks", `{"url":"`+server.URL+`","events":["created"]}`), fiber.StatusCreated, &webhook)
+	s.Equal(fiber.StatusCreated, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodPost, "/api/threads", `{"content":"hello world"}`).StatusCode)
+
+	// the first failure is retried, the second one runs out of attempts
+	s.Equal(1, s.dispatch())
+	<-received
+	var deliveries []repo.Delivery
+	s.responseData(s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries?status=pending", ""), fiber.StatusOK, &deliveries)
+	s.Equal(1, len(deliveries))
+	s.Equal(1, deliveries[0].Attempts)
+	s.Equal(fiber.StatusInternalServerError, deliveries[0].ResponseStatus)
+
+	s.Equal(1, s.dispatch())
+	<-received
+	s.Equal(0, s.dispatch())
+
+	var dead []repo.Delivery
+	s.responseData(s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/dead-letters", ""), fiber.StatusOK, &dead)
+	s.Equal(1, len(dead))
+	s.Equal(repo.DeliveryDead, dead[0].Status)
+	s.Equal(2, dead[0].Attempts)
+	s.Equal("unexpected status 500", dead[0].LastError)
+
+	s.Equal(fiber.StatusBadRequest, s.sendAs("root", auth.RoleAdmin, fiber.MethodGet, "/api/webhooks/"+webhook.ID+"/deliveries?status=lost", "").StatusCode)
+	s.Equal(fiber.StatusForbidden, s.sendAs("ramamimu", auth.RoleUser, fiber.MethodGet, "/api/webhooks/dead-letters", "").StatusCode)
+}
@@ -1,13 +1,25 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
 	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
 
+	"gofiber-api/auth"
+	"gofiber-api/config"
+	"gofiber-api/events"
 	handler "gofiber-api/httphandler"
 	midware "gofiber-api/middleware"
 	repo "gofiber-api/repository"
 	"gofiber-api/router"
 	service "gofiber-api/service"
+	"gofiber-api/shutdown"
 
 	"github.com/gofiber/fiber/v2"
 )
@@ -15,24 +27,176 @@ import (
 // refactor app
 
 func main() {
-	app := fiber.New()
+	cfg, err := config.Load(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	app := fiber.New(cfg.Fiber())
+	// stopped in the reverse order they are registered
+	hooks := shutdown.NewHooks()
 
 	app.Get("/ping", func(c *fiber.Ctx) error {
 		return c.SendString("pong")
 	})
 
-	db := repo.Db{}
-	db.Init()
+	var threadRepo interface {
+		service.RepositoryThread
+		service.RepositoryTrash
+		service.RepositoryWebhook
+		service.RepositoryOutbox
+	}
+	switch cfg.Store.Kind {
+	case config.StoreMemory:
+		db := repo.Db{}
+		db.Init()
+		threadRepo = &db
+	case config.StoreFile:
+		db, err := repo.OpenFileDb(cfg.Store.DataDir, 0)
+		if err != nil {
+			log.Fatal(err)
+		}
+		hooks.Add("store", func(ctx context.Context) error { return db.Close() })
+		threadRepo = db
+	case config.StoreSqlite:
+		db, err := repo.OpenSqliteDb(filepath.Join(cfg.Store.DataDir, "threads.db"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		hooks.Add("store", func(ctx context.Context) error { return db.Close() })
+		threadRepo = db
+	}
+
+	users := auth.NewUserStore()
+	if cfg.Auth.UsersFile != "" {
+		if users, err = auth.LoadUserStore(cfg.Auth.UsersFile); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		log.Print("no users file given, nobody can log in")
+	}
+
+	secret := []byte(cfg.Auth.Secret)
+	if len(secret) == 0 {
+		log.Printf("%s is not set, tokens will not survive a restart", config.EnvName("auth-secret"))
+		secret = make([]byte, config.MinSecretLen)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal(err)
+		}
+	}
+	signer := auth.NewSigner(secret, cfg.Auth.TokenTTL)
+
+	policy := auth.DefaultPolicy()
+	if cfg.Auth.RolesFile != "" {
+		if policy, err = auth.LoadPolicy(cfg.Auth.RolesFile); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// middleware
 	errorHandlerMiddleware := midware.NewErrorHandlerMiddleware(app)
-	errorHandlerMiddleware.Bind()
+	errorHandlerMiddleware.BindTo(cfg.Server.Prefix)
+	authMiddleware := midware.NewAuthMiddleware(app, signer)
+	authMiddleware.BindTo(cfg.Server.Prefix)
+
+	authService := service.NewAuth(users, signer)
+	authHandler := handler.NewAuthHandler(authService)
+	timeouts := []router.RouteOption{
+		router.WithReadTimeout(cfg.Server.ReadHandlerTimeout),
+		router.WithWriteTimeout(cfg.Server.WriteHandlerTimeout),
+	}
+	authRouter := router.NewAuthRoute(authHandler, timeouts...)
+
+	var relayOpts []service.RelayOption
+
+	hub := events.NewHub()
+	if cfg.Features.Stream || cfg.Features.Sockets {
+		relayOpts = append(relayOpts, service.WithSink(hub))
+	}
+
+	if cfg.Features.Webhooks {
+		dispatcher := service.NewDispatcher(threadRepo,
+			service.WithMaxAttempts(cfg.Webhooks.Attempts),
+			service.WithRetryBackoff(cfg.Webhooks.Backoff, service.DefaultMaxRetryBackoff),
+		)
+		hooks.Go("dispatcher", dispatcher.Run)
+		relayOpts = append(relayOpts, service.WithSink(dispatcher))
+	}
 
-	threadService := service.NewThread(&db)
+	if cfg.Store.EventLog != "" {
+		sink, err := events.OpenFileSink(cfg.Store.EventLog)
+		if err != nil {
+			log.Fatal(err)
+		}
+		hooks.Add("event log", func(ctx context.Context) error { return sink.Close() })
+		relayOpts = append(relayOpts, service.WithSink(sink))
+	}
+	relay := service.NewRelay(threadRepo, relayOpts...)
+	hooks.Go("relay", relay.Run)
+
+	threadService := service.NewThread(threadRepo,
+		service.WithPolicy(policy),
+		service.WithMaxReplyDepth(cfg.Threads.MaxReplyDepth),
+		service.WithNotifier(relay),
+	)
 	threadHandler := handler.NewThreadHandler(threadService)
-	threadRouter := router.NewThreadRoute(threadHandler)
+	threadRouter := router.NewThreadRoute(threadHandler, timeouts...)
+
+	purger := service.NewPurger(threadRepo, cfg.Threads.TrashRetention, cfg.Threads.PurgeInterval)
+	hooks.Go("purger", purger.Run)
 
-	api := app.Group("/api")
+	api := app.Group(cfg.Server.Prefix)
+	authRouter.Route(api)
+	// the stream is routed before /threads/:id would catch it
+	if cfg.Features.Stream {
+		router.NewStreamRoute(handler.NewStreamHandler(hub)).Route(api)
+	}
 	threadRouter.Route(api)
-	log.Fatal(app.Listen(":3001"))
+	if cfg.Features.Sockets {
+		router.NewSocketRoute(handler.NewSocketHandler(hub, threadService)).Route(api)
+	}
+	if cfg.Features.Webhooks {
+		webhookService := service.NewWebhook(threadRepo, policy)
+		webhookHandler := handler.NewWebhookHandler(webhookService)
+		router.NewWebhookRoute(webhookHandler, timeouts...).Route(api)
+	}
+
+	hooks.Add("server", app.ShutdownWithContext)
+	// ends the streams and sockets, the server would wait for them
+	hooks.Add("hub", func(ctx context.Context) error {
+		hub.Close()
+		return nil
+	})
+
+	signals, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(cfg.Server.Addr)
+	}()
+
+	select {
+	case err = <-listenErr:
+		log.Print(err)
+	case <-signals.Done():
+		log.Print("shutting down")
+	}
+	// a second signal kills the process right away
+	stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
+	if stopErr := hooks.Run(ctx); stopErr != nil {
+		log.Print(stopErr)
+		err = errors.Join(err, stopErr)
+	}
+	if err != nil {
+		cancel()
+		os.Exit(1)
+	}
+	log.Print("stopped")
 }
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"strings"
+
+	"gofiber-api/auth"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type TokenVerifier interface {
+	Verify(token string) (auth.Identity, error)
+}
+
+type AuthMiddleware struct {
+	app      *fiber.App
+	verifier TokenVerifier
+}
+
+func NewAuthMiddleware(app *fiber.App, verifier TokenVerifier) *AuthMiddleware {
+	return &AuthMiddleware{
+		app:      app,
+		verifier: verifier,
+	}
+}
+
+// Authenticate verifies the bearer token of the request and records its
+// identity. Requests without a token go through anonymously, handlers
+// decide whether they need an identity.
+func (am *AuthMiddleware) Authenticate(c *fiber.Ctx) error {
+	header := c.Get(fiber.HeaderAuthorization)
+	if header == "" {
+		return c.Next()
+	}
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return auth.ErrInvalidToken
+	}
+
+	identity, err := am.verifier.Verify(strings.TrimSpace(token))
+	if err != nil {
+		return err
+	}
+
+	auth.SetIdentity(c, identity)
+	return c.Next()
+}
+
+// Bind must be called after the error handler is bound so the errors of
+// Authenticate are rendered by it.
+func (am *AuthMiddleware) Bind() {
+	am.BindTo("/api")
+}
+
+// BindTo authenticates the requests under prefix instead of /api.
+func (am *AuthMiddleware) BindTo(prefix string) {
+	am.app.Use(prefix, am.Authenticate)
+}
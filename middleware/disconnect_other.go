@@ -0,0 +1,14 @@
+//go:build !unix
+
+package middleware
+
+import (
+	"context"
+	"net"
+)
+
+// watchDisconnect cannot peek at sockets here, only the deadline of a
+// request cancels it.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	return func() {}
+}
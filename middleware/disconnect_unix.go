@@ -0,0 +1,52 @@
+//go:build unix
+
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// watchDisconnect calls cancel once the client closes conn, until the
+// returned stop is called. It peeks at the socket without reading it, a
+// client that already sent its next request cannot be told apart from one
+// still waiting and is no longer watched. Connections that are not plain
+// sockets, such as TLS ones, are not watched.
+func watchDisconnect(conn net.Conn, cancel context.CancelFunc) (stop func()) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return func() {}
+	}
+	rc, err := sc.SyscallConn()
+	if err != nil {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		peek := make([]byte, 1)
+		rc.Read(func(fd uintptr) bool {
+			n, _, err := syscall.Recvfrom(int(fd), peek, syscall.MSG_PEEK)
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, syscall.EINTR) {
+				return false
+			}
+			// nothing to read from a readable socket is the end of it
+			if err != nil || n == 0 {
+				cancel()
+			}
+			return true
+		})
+	}()
+
+	return func() {
+		// the past deadline wakes the watcher, the server sets its own
+		// before reading the next request
+		conn.SetReadDeadline(time.Unix(1, 0))
+		<-done
+		conn.SetReadDeadline(time.Time{})
+	}
+}
@@ -1,9 +1,23 @@
 package middleware
 
 import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"gofiber-api/auth"
+	handler "gofiber-api/httphandler"
+	repo "gofiber-api/repository"
+
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
 )
 
+// StatusClientClosedRequest answers a request given up on by its client,
+// it has no name in fiber.
+const StatusClientClosedRequest = 499
+
 type ErrorHandlerMiddleware struct {
 	app *fiber.App
 }
@@ -14,16 +28,82 @@ func NewErrorHandlerMiddleware(app *fiber.App) *ErrorHandlerMiddleware {
 	}
 }
 
+// ErrorHandler turns any error returned further down the chain into a
+// ResponseType with the matching status code.
 func (eh *ErrorHandlerMiddleware) ErrorHandler(c *fiber.Ctx) error {
 	err := c.Next()
 
 	if err != nil {
-		return err
+		response := errorResponse(err)
+		if response.Status == fiber.StatusUnauthorized {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		}
+		return c.Status(response.Status).JSON(response)
 	}
 
 	return nil
 }
 
+func errorResponse(err error) handler.ResponseType {
+	var validationErr *repo.ValidationError
+	if errors.As(err, &validationErr) {
+		return handler.ResponseType{
+			Status:  fiber.StatusBadRequest,
+			Message: "Validation failed",
+			Data:    validationErr.Fields,
+		}
+	}
+
+	var fiberErr *fiber.Error
+	if errors.As(err, &fiberErr) {
+		return newErrorResponse(fiberErr.Code, fiberErr.Message)
+	}
+
+	switch {
+	case errors.Is(err, auth.ErrUnauthorized):
+		return newErrorResponse(fiber.StatusUnauthorized, err.Error())
+	case errors.Is(err, auth.ErrForbidden):
+		return newErrorResponse(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, repo.ErrValidation):
+		return newErrorResponse(fiber.StatusBadRequest, err.Error())
+	case errors.Is(err, repo.ErrNotFound):
+		return newErrorResponse(fiber.StatusNotFound, err.Error())
+	case errors.Is(err, repo.ErrConflict):
+		return newErrorResponse(fiber.StatusConflict, err.Error())
+	case errors.Is(err, repo.ErrPreconditionFailed):
+		return newErrorResponse(fiber.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, repo.ErrCanceled) && errors.Is(err, context.DeadlineExceeded):
+		return newErrorResponse(fiber.StatusGatewayTimeout, err.Error())
+	case errors.Is(err, repo.ErrCanceled):
+		return newErrorResponse(StatusClientClosedRequest, err.Error())
+	case errors.Is(err, repo.ErrInternal):
+		return newErrorResponse(fiber.StatusInternalServerError, err.Error())
+	}
+
+	// unknown errors may carry details that are not meant for clients
+	log.Printf("unhandled error: %v", err)
+	return newErrorResponse(fiber.StatusInternalServerError, utils.StatusMessage(fiber.StatusInternalServerError))
+}
+
+func newErrorResponse(status int, detail string) handler.ResponseType {
+	message := strings.ToLower(utils.StatusMessage(status))
+	if status == StatusClientClosedRequest {
+		message = "client closed request"
+	}
+	return handler.ResponseType{
+		Status:  status,
+		Message: message,
+		Data: []string{
+			detail,
+		},
+	}
+}
+
 func (eh *ErrorHandlerMiddleware) Bind() {
-	eh.app.Use("/api", eh.ErrorHandler)
+	eh.BindTo("/api")
+}
+
+// BindTo handles the errors of the requests under prefix instead of /api.
+func (eh *ErrorHandlerMiddleware) BindTo(prefix string) {
+	eh.app.Use(prefix, eh.ErrorHandler)
 }
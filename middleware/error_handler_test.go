@@ -0,0 +1,91 @@
+package middleware_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	handler "gofiber-api/httphandler"
+	midware "gofiber-api/middleware"
+	repo "gofiber-api/repository"
+)
+
+type ErrorHandlerSuite struct {
+	suite.Suite
+	app *fiber.App
+	err error
+}
+
+func TestErrorHandlerSuite(t *testing.T) {
+	suite.Run(t, new(ErrorHandlerSuite))
+}
+
+func (s *ErrorHandlerSuite) SetupSuite() {
+	s.app = fiber.New()
+
+	errorHandlerMiddleware := midware.NewErrorHandlerMiddleware(s.app)
+	errorHandlerMiddleware.Bind()
+
+	s.app.Get("/api/fail", func(c *fiber.Ctx) error {
+		return s.err
+	})
+}
+
+func (s *ErrorHandlerSuite) request(path string) handler.ResponseType {
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodGet, path, nil))
+	s.Require().NoError(err)
+
+	bodyString, err := io.ReadAll(resp.Body)
+	s.Require().NoError(err)
+
+	var response handler.ResponseType
+	s.Require().NoError(json.Unmarshal(bodyString, &response))
+	s.Equal(resp.StatusCode, response.Status)
+	return response
+}
+
+func (s *ErrorHandlerSuite) TestMapping() {
+	cases := []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{repo.ErrThreadNotFound, fiber.StatusNotFound, "not found"},
+		{fmt.Errorf("loading: %w", repo.ErrNotFound), fiber.StatusNotFound, "not found"},
+		{repo.ErrConflict, fiber.StatusConflict, "conflict"},
+		{repo.ErrInvalidCursor, fiber.StatusBadRequest, "bad request"},
+		{repo.NewValidationError("Content is required"), fiber.StatusBadRequest, "Validation failed"},
+		{repo.ErrInternal, fiber.StatusInternalServerError, "internal server error"},
+		{fmt.Errorf("%w: %w", repo.ErrCanceled, context.DeadlineExceeded), fiber.StatusGatewayTimeout, "gateway timeout"},
+		{fmt.Errorf("%w: %w", repo.ErrCanceled, context.Canceled), midware.StatusClientClosedRequest, "client closed request"},
+		{fiber.NewError(fiber.StatusTeapot, "short and stout"), fiber.StatusTeapot, "i'm a teapot"},
+		{errors.New("secret details"), fiber.StatusInternalServerError, "internal server error"},
+	}
+
+	for _, tc := range cases {
+		s.err = tc.err
+		response := s.request("/api/fail")
+		s.Equal(tc.status, response.Status, tc.err.Error())
+		s.Equal(tc.message, response.Message, tc.err.Error())
+		s.NotContains(fmt.Sprint(response.Data), "secret")
+	}
+}
+
+func (s *ErrorHandlerSuite) TestValidationFields() {
+	s.err = repo.NewValidationError("Content is required", "Author is required")
+	response := s.request("/api/fail")
+	s.Equal([]interface{}{"Content is required", "Author is required"}, response.Data)
+}
+
+func (s *ErrorHandlerSuite) TestUnknownRoute() {
+	response := s.request("/api/missing")
+	s.Equal(fiber.StatusNotFound, response.Status)
+	s.Equal("not found", response.Message)
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Timeout bounds the context the handlers after it pass down, the stores
+// give up once it is done and the request is answered with a 504. The
+// context is also canceled once the client closes its connection, the
+// request is then answered with a 499 nobody reads. A zero timeout leaves
+// the requests unbounded.
+func Timeout(timeout time.Duration) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		ctx, cancel := context.WithCancel(c.UserContext())
+		defer cancel()
+		stop := watchDisconnect(c.Context().Conn(), cancel)
+		defer stop()
+
+		if timeout > 0 {
+			var cancelTimeout context.CancelFunc
+			ctx, cancelTimeout = context.WithTimeout(ctx, timeout)
+			defer cancelTimeout()
+		}
+
+		c.SetUserContext(ctx)
+		return c.Next()
+	}
+}
@@ -0,0 +1,129 @@
+package middleware_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/suite"
+
+	midware "gofiber-api/middleware"
+	repo "gofiber-api/repository"
+)
+
+type TimeoutSuite struct {
+	suite.Suite
+	app *fiber.App
+	ln  net.Listener
+	db  repo.Db
+	// hung receives the error of the context of the hanging requests
+	hung chan error
+}
+
+func TestTimeoutSuite(t *testing.T) {
+	suite.Run(t, new(TimeoutSuite))
+}
+
+func (s *TimeoutSuite) SetupSuite() {
+	s.app = fiber.New(fiber.Config{DisableStartupMessage: true})
+	s.hung = make(chan error, 1)
+	s.db = repo.Db{}
+	s.db.Init()
+
+	errorHandlerMiddleware := midware.NewErrorHandlerMiddleware(s.app)
+	errorHandlerMiddleware.Bind()
+
+	// the handler is slower than its timeout, the store notices
+	slow := func(c *fiber.Ctx) error {
+		select {
+		case <-c.UserContext().Done():
+		case <-time.After(time.Second):
+		}
+		_, err := s.db.AddThread(c.UserContext(), "the-author", "the content")
+		if err != nil {
+			return err
+		}
+		return c.SendStatus(fiber.StatusCreated)
+	}
+	s.app.Post("/api/slow", midware.Timeout(10*time.Millisecond), slow)
+	s.app.Post("/api/unbounded", midware.Timeout(0), func(c *fiber.Ctx) error {
+		_, hasDeadline := c.UserContext().Deadline()
+		s.False(hasDeadline)
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+	s.app.Post("/api/hang", midware.Timeout(0), func(c *fiber.Ctx) error {
+		ctx := c.UserContext()
+		select {
+		case <-ctx.Done():
+		case <-time.After(time.Second):
+		}
+		s.hung <- ctx.Err()
+		_, err := s.db.AddThread(ctx, "the-author", "the content")
+		return err
+	})
+
+	// a disconnect is only seen on a real connection
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	s.Require().NoError(err)
+	s.ln = ln
+	go s.app.Listener(ln)
+}
+
+func (s *TimeoutSuite) TearDownSuite() {
+	s.Require().NoError(s.app.Shutdown())
+}
+
+func (s *TimeoutSuite) post(conn net.Conn, path string) {
+	_, err := conn.Write([]byte("POST " + path + " HTTP/1.1\r\nHost: test\r\nContent-Length: 0\r\n\r\n"))
+	s.Require().NoError(err)
+}
+
+func (s *TimeoutSuite) TestDeadlineIsGatewayTimeout() {
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodPost, "/api/slow", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusGatewayTimeout, resp.StatusCode)
+	s.Empty(s.db.GetThreads(context.Background()))
+}
+
+func (s *TimeoutSuite) TestZeroTimeoutIsUnbounded() {
+	resp, err := s.app.Test(httptest.NewRequest(fiber.MethodPost, "/api/unbounded", nil))
+	s.Require().NoError(err)
+	s.Equal(fiber.StatusNoContent, resp.StatusCode)
+}
+
+func (s *TimeoutSuite) TestDisconnectCancels() {
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	s.Require().NoError(err)
+	s.post(conn, "/api/hang")
+	s.Require().NoError(conn.Close())
+
+	select {
+	case err := <-s.hung:
+		s.ErrorIs(err, context.Canceled)
+	case <-time.After(2 * time.Second):
+		s.Fail("the handler was not canceled")
+	}
+	s.Empty(s.db.GetThreads(context.Background()))
+}
+
+func (s *TimeoutSuite) TestConnectionIsKeptAlive() {
+	conn, err := net.Dial("tcp", s.ln.Addr().String())
+	s.Require().NoError(err)
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	// watching the first request must leave the connection readable for
+	// the second one
+	for i := 0; i < 2; i++ {
+		s.post(conn, "/api/unbounded")
+		resp, err := http.ReadResponse(r, nil)
+		s.Require().NoError(err)
+		resp.Body.Close()
+		s.Equal(fiber.StatusNoContent, resp.StatusCode)
+	}
+}
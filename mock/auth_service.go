@@ -0,0 +1,88 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: service/auth.go
+
+// Package mocker is a generated GoMock package.
+package mocker
+
+import (
+	auth "gofiber-api/auth"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockUserAuthenticator is a mock of UserAuthenticator interface.
+type MockUserAuthenticator struct {
+	ctrl     *gomock.Controller
+	recorder *MockUserAuthenticatorMockRecorder
+}
+
+// MockUserAuthenticatorMockRecorder is the mock recorder for MockUserAuthenticator.
+type MockUserAuthenticatorMockRecorder struct {
+	mock *MockUserAuthenticator
+}
+
+// NewMockUserAuthenticator creates a new mock instance.
+func NewMockUserAuthenticator(ctrl *gomock.Controller) *MockUserAuthenticator {
+	mock := &MockUserAuthenticator{ctrl: ctrl}
+	mock.recorder = &MockUserAuthenticatorMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockUserAuthenticator) EXPECT() *MockUserAuthenticatorMockRecorder {
+	return m.recorder
+}
+
+// Authenticate mocks base method.
+func (m *MockUserAuthenticator) Authenticate(username, password string) (auth.Identity, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Authenticate", username, password)
+	ret0, _ := ret[0].(auth.Identity)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Authenticate indicates an expected call of Authenticate.
+func (mr *MockUserAuthenticatorMockRecorder) Authenticate(username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Authenticate", reflect.TypeOf((*MockUserAuthenticator)(nil).Authenticate), username, password)
+}
+
+// MockTokenIssuer is a mock of TokenIssuer interface.
+type MockTokenIssuer struct {
+	ctrl     *gomock.Controller
+	recorder *MockTokenIssuerMockRecorder
+}
+
+// MockTokenIssuerMockRecorder is the mock recorder for MockTokenIssuer.
+type MockTokenIssuerMockRecorder struct {
+	mock *MockTokenIssuer
+}
+
+// NewMockTokenIssuer creates a new mock instance.
+func NewMockTokenIssuer(ctrl *gomock.Controller) *MockTokenIssuer {
+	mock := &MockTokenIssuer{ctrl: ctrl}
+	mock.recorder = &MockTokenIssuerMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockTokenIssuer) EXPECT() *MockTokenIssuerMockRecorder {
+	return m.recorder
+}
+
+// Issue mocks base method.
+func (m *MockTokenIssuer) Issue(identity auth.Identity) (auth.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Issue", identity)
+	ret0, _ := ret[0].(auth.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Issue indicates an expected call of Issue.
+func (mr *MockTokenIssuerMockRecorder) Issue(identity interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Issue", reflect.TypeOf((*MockTokenIssuer)(nil).Issue), identity)
+}
@@ -0,0 +1,51 @@
+// Code generated by MockGen. DO NOT EDIT.
+// Source: httphandler/auth.go
+
+// Package mocker is a generated GoMock package.
+package mocker
+
+import (
+	context "context"
+	auth "gofiber-api/auth"
+	reflect "reflect"
+
+	gomock "github.com/golang/mock/gomock"
+)
+
+// MockHttpAuthHandlerRepo is a mock of HttpAuthHandlerRepo interface.
+type MockHttpAuthHandlerRepo struct {
+	ctrl     *gomock.Controller
+	recorder *MockHttpAuthHandlerRepoMockRecorder
+}
+
+// MockHttpAuthHandlerRepoMockRecorder is the mock recorder for MockHttpAuthHandlerRepo.
+type MockHttpAuthHandlerRepoMockRecorder struct {
+	mock *MockHttpAuthHandlerRepo
+}
+
+// NewMockHttpAuthHandlerRepo creates a new mock instance.
+func NewMockHttpAuthHandlerRepo(ctrl *gomock.Controller) *MockHttpAuthHandlerRepo {
+	mock := &MockHttpAuthHandlerRepo{ctrl: ctrl}
+	mock.recorder = &MockHttpAuthHandlerRepoMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockHttpAuthHandlerRepo) EXPECT() *MockHttpAuthHandlerRepoMockRecorder {
+	return m.recorder
+}
+
+// Login mocks base method.
+func (m *MockHttpAuthHandlerRepo) Login(ctx context.Context, username, password string) (auth.Token, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Login", ctx, username, password)
+	ret0, _ := ret[0].(auth.Token)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Login indicates an expected call of Login.
+func (mr *MockHttpAuthHandlerRepoMockRecorder) Login(ctx, username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Login", reflect.TypeOf((*MockHttpAuthHandlerRepo)(nil).Login), ctx, username, password)
+}
@@ -35,19 +35,68 @@ func (m *MockRepositoryThread) EXPECT() *MockRepositoryThreadMockRecorder {
 	return m.recorder
 }
 
+// AddReaction mocks base method.
+func (m *MockRepositoryThread) AddReaction(ctx context.Context, threadID, username, kind string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReaction", ctx, threadID, username, kind)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddReaction indicates an expected call of AddReaction.
+func (mr *MockRepositoryThreadMockRecorder) AddReaction(ctx, threadID, username, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReaction", reflect.TypeOf((*MockRepositoryThread)(nil).AddReaction), ctx, threadID, username, kind)
+}
+
+// AddReply mocks base method.
+func (m *MockRepositoryThread) AddReply(ctx context.Context, threadID, parentID, author, content string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddReply", ctx, threadID, parentID, author, content)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddReply indicates an expected call of AddReply.
+func (mr *MockRepositoryThreadMockRecorder) AddReply(ctx, threadID, parentID, author, content interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddReply", reflect.TypeOf((*MockRepositoryThread)(nil).AddReply), ctx, threadID, parentID, author, content)
+}
+
 // AddThread mocks base method.
-func (m *MockRepositoryThread) AddThread(ctx context.Context, author, content string) (string, error) {
+func (m *MockRepositoryThread) AddThread(ctx context.Context, author, content string, tags ...string) (repository.Thread, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "AddThread", ctx, author, content)
-	ret0, _ := ret[0].(string)
+	varargs := []interface{}{ctx, author, content}
+	for _, a := range tags {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "AddThread", varargs...)
+	ret0, _ := ret[0].(repository.Thread)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
 // AddThread indicates an expected call of AddThread.
-func (mr *MockRepositoryThreadMockRecorder) AddThread(ctx, author, content interface{}) *gomock.Call {
+func (mr *MockRepositoryThreadMockRecorder) AddThread(ctx, author, content interface{}, tags ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, author, content}, tags...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddThread", reflect.TypeOf((*MockRepositoryThread)(nil).AddThread), varargs...)
+}
+
+// DeleteReply mocks base method.
+func (m *MockRepositoryThread) DeleteReply(ctx context.Context, threadID, replyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteReply", ctx, threadID, replyID)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeleteReply indicates an expected call of DeleteReply.
+func (mr *MockRepositoryThreadMockRecorder) DeleteReply(ctx, threadID, replyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddThread", reflect.TypeOf((*MockRepositoryThread)(nil).AddThread), ctx, author, content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteReply", reflect.TypeOf((*MockRepositoryThread)(nil).DeleteReply), ctx, threadID, replyID)
 }
 
 // DeleteThread mocks base method.
@@ -64,18 +113,139 @@ func (mr *MockRepositoryThreadMockRecorder) DeleteThread(ctx, id interface{}) *g
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteThread", reflect.TypeOf((*MockRepositoryThread)(nil).DeleteThread), ctx, id)
 }
 
+// EditReply mocks base method.
+func (m *MockRepositoryThread) EditReply(ctx context.Context, threadID, replyID, content string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "EditReply", ctx, threadID, replyID, content)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// EditReply indicates an expected call of EditReply.
+func (mr *MockRepositoryThreadMockRecorder) EditReply(ctx, threadID, replyID, content interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditReply", reflect.TypeOf((*MockRepositoryThread)(nil).EditReply), ctx, threadID, replyID, content)
+}
+
 // EditThread mocks base method.
-func (m *MockRepositoryThread) EditThread(ctx context.Context, id, content string) error {
+func (m *MockRepositoryThread) EditThread(ctx context.Context, id string, edit repository.ThreadEdit) (repository.Thread, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "EditThread", ctx, id, content)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "EditThread", ctx, id, edit)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // EditThread indicates an expected call of EditThread.
-func (mr *MockRepositoryThreadMockRecorder) EditThread(ctx, id, content interface{}) *gomock.Call {
+func (mr *MockRepositoryThreadMockRecorder) EditThread(ctx, id, edit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditThread", reflect.TypeOf((*MockRepositoryThread)(nil).EditThread), ctx, id, edit)
+}
+
+// GetReplies mocks base method.
+func (m *MockRepositoryThread) GetReplies(ctx context.Context, threadID string) ([]repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReplies", ctx, threadID)
+	ret0, _ := ret[0].([]repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReplies indicates an expected call of GetReplies.
+func (mr *MockRepositoryThreadMockRecorder) GetReplies(ctx, threadID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplies", reflect.TypeOf((*MockRepositoryThread)(nil).GetReplies), ctx, threadID)
+}
+
+// GetReply mocks base method.
+func (m *MockRepositoryThread) GetReply(ctx context.Context, threadID, replyID string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReply", ctx, threadID, replyID)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReply indicates an expected call of GetReply.
+func (mr *MockRepositoryThreadMockRecorder) GetReply(ctx, threadID, replyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReply", reflect.TypeOf((*MockRepositoryThread)(nil).GetReply), ctx, threadID, replyID)
+}
+
+// GetReplyTree mocks base method.
+func (m *MockRepositoryThread) GetReplyTree(ctx context.Context, threadID, rootID string, depth int) ([]repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetReplyTree", ctx, threadID, rootID, depth)
+	ret0, _ := ret[0].([]repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetReplyTree indicates an expected call of GetReplyTree.
+func (mr *MockRepositoryThreadMockRecorder) GetReplyTree(ctx, threadID, rootID, depth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetReplyTree", reflect.TypeOf((*MockRepositoryThread)(nil).GetReplyTree), ctx, threadID, rootID, depth)
+}
+
+// GetRevision mocks base method.
+func (m *MockRepositoryThread) GetRevision(ctx context.Context, id string, number int) (repository.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevision", ctx, id, number)
+	ret0, _ := ret[0].(repository.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevision indicates an expected call of GetRevision.
+func (mr *MockRepositoryThreadMockRecorder) GetRevision(ctx, id, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevision", reflect.TypeOf((*MockRepositoryThread)(nil).GetRevision), ctx, id, number)
+}
+
+// GetRevisions mocks base method.
+func (m *MockRepositoryThread) GetRevisions(ctx context.Context, id string) ([]repository.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetRevisions", ctx, id)
+	ret0, _ := ret[0].([]repository.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetRevisions indicates an expected call of GetRevisions.
+func (mr *MockRepositoryThreadMockRecorder) GetRevisions(ctx, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "EditThread", reflect.TypeOf((*MockRepositoryThread)(nil).EditThread), ctx, id, content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetRevisions", reflect.TypeOf((*MockRepositoryThread)(nil).GetRevisions), ctx, id)
+}
+
+// GetTags mocks base method.
+func (m *MockRepositoryThread) GetTags(ctx context.Context) ([]repository.TagCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTags", ctx)
+	ret0, _ := ret[0].([]repository.TagCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTags indicates an expected call of GetTags.
+func (mr *MockRepositoryThreadMockRecorder) GetTags(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTags", reflect.TypeOf((*MockRepositoryThread)(nil).GetTags), ctx)
+}
+
+// GetThreadByID mocks base method.
+func (m *MockRepositoryThread) GetThreadByID(ctx context.Context, id string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetThreadByID", ctx, id)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetThreadByID indicates an expected call of GetThreadByID.
+func (mr *MockRepositoryThreadMockRecorder) GetThreadByID(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreadByID", reflect.TypeOf((*MockRepositoryThread)(nil).GetThreadByID), ctx, id)
 }
 
 // GetThreads mocks base method.
@@ -91,3 +261,113 @@ func (mr *MockRepositoryThreadMockRecorder) GetThreads(ctx interface{}) *gomock.
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetThreads", reflect.TypeOf((*MockRepositoryThread)(nil).GetThreads), ctx)
 }
+
+// GetTrash mocks base method.
+func (m *MockRepositoryThread) GetTrash(ctx context.Context) ([]repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTrash", ctx)
+	ret0, _ := ret[0].([]repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTrash indicates an expected call of GetTrash.
+func (mr *MockRepositoryThreadMockRecorder) GetTrash(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTrash", reflect.TypeOf((*MockRepositoryThread)(nil).GetTrash), ctx)
+}
+
+// ListThreads mocks base method.
+func (m *MockRepositoryThread) ListThreads(ctx context.Context, opts repository.ListOptions) (repository.ThreadPage, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ListThreads", ctx, opts)
+	ret0, _ := ret[0].(repository.ThreadPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListThreads indicates an expected call of ListThreads.
+func (mr *MockRepositoryThreadMockRecorder) ListThreads(ctx, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListThreads", reflect.TypeOf((*MockRepositoryThread)(nil).ListThreads), ctx, opts)
+}
+
+// RemoveReaction mocks base method.
+func (m *MockRepositoryThread) RemoveReaction(ctx context.Context, threadID, username, kind string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveReaction", ctx, threadID, username, kind)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RemoveReaction indicates an expected call of RemoveReaction.
+func (mr *MockRepositoryThreadMockRecorder) RemoveReaction(ctx, threadID, username, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReaction", reflect.TypeOf((*MockRepositoryThread)(nil).RemoveReaction), ctx, threadID, username, kind)
+}
+
+// RestoreThread mocks base method.
+func (m *MockRepositoryThread) RestoreThread(ctx context.Context, id string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreThread", ctx, id)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreThread indicates an expected call of RestoreThread.
+func (mr *MockRepositoryThreadMockRecorder) RestoreThread(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreThread", reflect.TypeOf((*MockRepositoryThread)(nil).RestoreThread), ctx, id)
+}
+
+// SearchThreads mocks base method.
+func (m *MockRepositoryThread) SearchThreads(ctx context.Context, query string, limit int) ([]repository.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SearchThreads", ctx, query, limit)
+	ret0, _ := ret[0].([]repository.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SearchThreads indicates an expected call of SearchThreads.
+func (mr *MockRepositoryThreadMockRecorder) SearchThreads(ctx, query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SearchThreads", reflect.TypeOf((*MockRepositoryThread)(nil).SearchThreads), ctx, query, limit)
+}
+
+// MockNotifier is a mock of Notifier interface.
+type MockNotifier struct {
+	ctrl     *gomock.Controller
+	recorder *MockNotifierMockRecorder
+}
+
+// MockNotifierMockRecorder is the mock recorder for MockNotifier.
+type MockNotifierMockRecorder struct {
+	mock *MockNotifier
+}
+
+// NewMockNotifier creates a new mock instance.
+func NewMockNotifier(ctrl *gomock.Controller) *MockNotifier {
+	mock := &MockNotifier{ctrl: ctrl}
+	mock.recorder = &MockNotifierMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockNotifier) EXPECT() *MockNotifierMockRecorder {
+	return m.recorder
+}
+
+// Notify mocks base method.
+func (m *MockNotifier) Notify() {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "Notify")
+}
+
+// Notify indicates an expected call of Notify.
+func (mr *MockNotifierMockRecorder) Notify() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Notify", reflect.TypeOf((*MockNotifier)(nil).Notify))
+}
@@ -6,6 +6,7 @@ package mocker
 
 import (
 	context "context"
+	auth "gofiber-api/auth"
 	repository "gofiber-api/repository"
 	reflect "reflect"
 
@@ -36,57 +37,299 @@ func (m *MockHttpThreadHandlerRepo) EXPECT() *MockHttpThreadHandlerRepoMockRecor
 }
 
 // Add mocks base method.
-func (m *MockHttpThreadHandlerRepo) Add(ctx context.Context, author, content string) error {
+func (m *MockHttpThreadHandlerRepo) Add(ctx context.Context, author, content string, tags []string) (repository.Thread, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Add", ctx, author, content)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Add", ctx, author, content, tags)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Add indicates an expected call of Add.
-func (mr *MockHttpThreadHandlerRepoMockRecorder) Add(ctx, author, content interface{}) *gomock.Call {
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Add(ctx, author, content, tags interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Add), ctx, author, content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Add", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Add), ctx, author, content, tags)
 }
 
 // Delete mocks base method.
-func (m *MockHttpThreadHandlerRepo) Delete(ctx context.Context, id string) error {
+func (m *MockHttpThreadHandlerRepo) Delete(ctx context.Context, actor auth.Identity, id string) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Delete", ctx, id)
+	ret := m.ctrl.Call(m, "Delete", ctx, actor, id)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
 // Delete indicates an expected call of Delete.
-func (mr *MockHttpThreadHandlerRepoMockRecorder) Delete(ctx, id interface{}) *gomock.Call {
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Delete(ctx, actor, id interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Delete), ctx, id)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Delete", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Delete), ctx, actor, id)
 }
 
 // Edit mocks base method.
-func (m *MockHttpThreadHandlerRepo) Edit(ctx context.Context, id, content string) error {
+func (m *MockHttpThreadHandlerRepo) Edit(ctx context.Context, actor auth.Identity, id, content string, tags []string, version int) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Edit", ctx, actor, id, content, tags, version)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Edit indicates an expected call of Edit.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Edit(ctx, actor, id, content, tags, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Edit", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Edit), ctx, actor, id, content, tags, version)
+}
+
+// Get mocks base method.
+func (m *MockHttpThreadHandlerRepo) Get(ctx context.Context, id string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Get", ctx, id)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Get indicates an expected call of Get.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Get(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Get", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Get), ctx, id)
+}
+
+// List mocks base method.
+func (m *MockHttpThreadHandlerRepo) List(ctx context.Context, opts repository.ListOptions) (repository.ThreadPage, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Edit", ctx, id, content)
+	ret := m.ctrl.Call(m, "List", ctx, opts)
+	ret0, _ := ret[0].(repository.ThreadPage)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// List indicates an expected call of List.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) List(ctx, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "List", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).List), ctx, opts)
+}
+
+// PostReply mocks base method.
+func (m *MockHttpThreadHandlerRepo) PostReply(ctx context.Context, actor auth.Identity, threadID, parentID, content string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PostReply", ctx, actor, threadID, parentID, content)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PostReply indicates an expected call of PostReply.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) PostReply(ctx, actor, threadID, parentID, content interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PostReply", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).PostReply), ctx, actor, threadID, parentID, content)
+}
+
+// React mocks base method.
+func (m *MockHttpThreadHandlerRepo) React(ctx context.Context, actor auth.Identity, id, kind string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "React", ctx, actor, id, kind)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// React indicates an expected call of React.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) React(ctx, actor, id, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "React", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).React), ctx, actor, id, kind)
+}
+
+// RemoveReply mocks base method.
+func (m *MockHttpThreadHandlerRepo) RemoveReply(ctx context.Context, actor auth.Identity, threadID, replyID string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveReply", ctx, actor, threadID, replyID)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// Edit indicates an expected call of Edit.
-func (mr *MockHttpThreadHandlerRepoMockRecorder) Edit(ctx, id, content interface{}) *gomock.Call {
+// RemoveReply indicates an expected call of RemoveReply.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) RemoveReply(ctx, actor, threadID, replyID interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Edit", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Edit), ctx, id, content)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveReply", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).RemoveReply), ctx, actor, threadID, replyID)
 }
 
-// GetAll mocks base method.
-func (m *MockHttpThreadHandlerRepo) GetAll(ctx context.Context) []repository.Thread {
+// Replies mocks base method.
+func (m *MockHttpThreadHandlerRepo) Replies(ctx context.Context, threadID string) ([]repository.Reply, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GetAll", ctx)
+	ret := m.ctrl.Call(m, "Replies", ctx, threadID)
+	ret0, _ := ret[0].([]repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Replies indicates an expected call of Replies.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Replies(ctx, threadID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Replies", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Replies), ctx, threadID)
+}
+
+// Reply mocks base method.
+func (m *MockHttpThreadHandlerRepo) Reply(ctx context.Context, threadID, replyID string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Reply", ctx, threadID, replyID)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Reply indicates an expected call of Reply.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Reply(ctx, threadID, replyID interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Reply", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Reply), ctx, threadID, replyID)
+}
+
+// ReplyTree mocks base method.
+func (m *MockHttpThreadHandlerRepo) ReplyTree(ctx context.Context, threadID, rootID string, depth int) ([]repository.ReplyNode, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReplyTree", ctx, threadID, rootID, depth)
+	ret0, _ := ret[0].([]repository.ReplyNode)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReplyTree indicates an expected call of ReplyTree.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) ReplyTree(ctx, threadID, rootID, depth interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReplyTree", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).ReplyTree), ctx, threadID, rootID, depth)
+}
+
+// Restore mocks base method.
+func (m *MockHttpThreadHandlerRepo) Restore(ctx context.Context, actor auth.Identity, id string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Restore", ctx, actor, id)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Restore indicates an expected call of Restore.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Restore(ctx, actor, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Restore", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Restore), ctx, actor, id)
+}
+
+// RestoreRevision mocks base method.
+func (m *MockHttpThreadHandlerRepo) RestoreRevision(ctx context.Context, actor auth.Identity, id string, number, version int) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RestoreRevision", ctx, actor, id, number, version)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// RestoreRevision indicates an expected call of RestoreRevision.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) RestoreRevision(ctx, actor, id, number, version interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RestoreRevision", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).RestoreRevision), ctx, actor, id, number, version)
+}
+
+// Revision mocks base method.
+func (m *MockHttpThreadHandlerRepo) Revision(ctx context.Context, id string, number int) (repository.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revision", ctx, id, number)
+	ret0, _ := ret[0].(repository.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revision indicates an expected call of Revision.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Revision(ctx, id, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revision", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Revision), ctx, id, number)
+}
+
+// Revisions mocks base method.
+func (m *MockHttpThreadHandlerRepo) Revisions(ctx context.Context, id string) ([]repository.Revision, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Revisions", ctx, id)
+	ret0, _ := ret[0].([]repository.Revision)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Revisions indicates an expected call of Revisions.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Revisions(ctx, id interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Revisions", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Revisions), ctx, id)
+}
+
+// Search mocks base method.
+func (m *MockHttpThreadHandlerRepo) Search(ctx context.Context, query string, limit int) ([]repository.SearchResult, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Search", ctx, query, limit)
+	ret0, _ := ret[0].([]repository.SearchResult)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Search indicates an expected call of Search.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Search(ctx, query, limit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Search", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Search), ctx, query, limit)
+}
+
+// Tags mocks base method.
+func (m *MockHttpThreadHandlerRepo) Tags(ctx context.Context) ([]repository.TagCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Tags", ctx)
+	ret0, _ := ret[0].([]repository.TagCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Tags indicates an expected call of Tags.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Tags(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Tags", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Tags), ctx)
+}
+
+// Trash mocks base method.
+func (m *MockHttpThreadHandlerRepo) Trash(ctx context.Context, actor auth.Identity) ([]repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Trash", ctx, actor)
 	ret0, _ := ret[0].([]repository.Thread)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Trash indicates an expected call of Trash.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Trash(ctx, actor interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Trash", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Trash), ctx, actor)
+}
+
+// Unreact mocks base method.
+func (m *MockHttpThreadHandlerRepo) Unreact(ctx context.Context, actor auth.Identity, id, kind string) (repository.Thread, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Unreact", ctx, actor, id, kind)
+	ret0, _ := ret[0].(repository.Thread)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Unreact indicates an expected call of Unreact.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) Unreact(ctx, actor, id, kind interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Unreact", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).Unreact), ctx, actor, id, kind)
+}
+
+// UpdateReply mocks base method.
+func (m *MockHttpThreadHandlerRepo) UpdateReply(ctx context.Context, actor auth.Identity, threadID, replyID, content string) (repository.Reply, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateReply", ctx, actor, threadID, replyID, content)
+	ret0, _ := ret[0].(repository.Reply)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// GetAll indicates an expected call of GetAll.
-func (mr *MockHttpThreadHandlerRepoMockRecorder) GetAll(ctx interface{}) *gomock.Call {
+// UpdateReply indicates an expected call of UpdateReply.
+func (mr *MockHttpThreadHandlerRepoMockRecorder) UpdateReply(ctx, actor, threadID, replyID, content interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAll", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).GetAll), ctx)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateReply", reflect.TypeOf((*MockHttpThreadHandlerRepo)(nil).UpdateReply), ctx, actor, threadID, replyID, content)
 }
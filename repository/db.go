@@ -2,8 +2,10 @@ package repository
 
 import (
 	"context"
-	"errors"
+	"gofiber-api/events"
+	"sort"
 	"strconv"
+	"sync"
 	"time"
 )
 
@@ -14,45 +16,205 @@ type Thread struct {
 	Author     string    `json:"author"`
 	Content    string    `json:"content"`
 	IsEdited   bool      `json:"is_edited"`
+	// Version starts at 1 and is bumped by every edit
+	Version int `json:"version"`
+	// DeletedAt is set while the thread is in the trash
+	DeletedAt  *time.Time `json:"deleted_at,omitempty"`
+	ReplyCount int        `json:"reply_count"`
+	// Reactions counts the reactions of each kind left on the thread
+	Reactions map[string]int `json:"reactions,omitempty"`
+	Score     int            `json:"score"`
+	// Tags are lowercase and sorted
+	Tags []string `json:"tags"`
 }
 
+// ThreadEdit describes a change of a thread made by Editor. An empty
+// Content keeps the content and nil Tags keep the tags.
+type ThreadEdit struct {
+	Content string
+	Tags    []string
+	Editor  string
+	// Version, when not zero, is the version the edit was based on
+	Version int
+}
+
+// Db is an in-memory thread store. Every method is safe for concurrent use.
 type Db struct {
+	mu        sync.RWMutex
 	threads   map[string]Thread
+	trash     map[string]Thread
+	revisions map[string][]Revision
+	replies   map[string][]Reply
+	reactions map[string][]Reaction
+	// tags indexes the ids of the threads outside the trash by tag
+	tags      map[string]map[string]bool
 	increment int
+	// replyIncrement numbers replies across every thread
+	replyIncrement    int
+	webhooks          map[string]Webhook
+	deliveries        map[string]Delivery
+	webhookIncrement  int
+	deliveryIncrement int
+	// outbox holds the events of the writes until they are relayed
+	outbox          map[int64]OutboxEntry
+	outboxIncrement int64
+	index           *searchIndex
 }
 
 func (db *Db) Init() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	db.increment = 0
+	db.replyIncrement = 0
+	db.webhookIncrement = 0
+	db.deliveryIncrement = 0
 	db.threads = make(map[string]Thread)
+	db.trash = make(map[string]Thread)
+	db.revisions = make(map[string][]Revision)
+	db.replies = make(map[string][]Reply)
+	db.reactions = make(map[string][]Reaction)
+	db.tags = make(map[string]map[string]bool)
+	db.webhooks = make(map[string]Webhook)
+	db.deliveries = make(map[string]Delivery)
+	db.outbox = make(map[int64]OutboxEntry)
+	db.outboxIncrement = 0
+	db.index = newSearchIndex()
 }
 
 func (db *Db) Clear() {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
 	for t := range db.threads {
 		delete(db.threads, t)
 	}
+	for t := range db.trash {
+		delete(db.trash, t)
+	}
+	for t := range db.revisions {
+		delete(db.revisions, t)
+	}
+	for t := range db.replies {
+		delete(db.replies, t)
+	}
+	for t := range db.reactions {
+		delete(db.reactions, t)
+	}
+	for t := range db.tags {
+		delete(db.tags, t)
+	}
+	for w := range db.webhooks {
+		delete(db.webhooks, w)
+	}
+	for d := range db.deliveries {
+		delete(db.deliveries, d)
+	}
+	for o := range db.outbox {
+		delete(db.outbox, o)
+	}
+	if db.index != nil {
+		db.index.clear()
+	}
 }
 
-func (db *Db) GetThreadByID(id string) (Thread, error) {
+func (db *Db) GetThreadByID(ctx context.Context, id string) (Thread, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
 	val, ok := db.threads[id]
 	if !ok {
-		return Thread{}, errors.New("thread not found")
+		return Thread{}, ErrThreadNotFound
 	}
 	return val, nil
 }
 
+// GetThreadsEntity returns a copy of the underlying map so callers can
+// inspect it without holding the lock.
 func (db *Db) GetThreadsEntity() map[string]Thread {
-	return db.threads
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	threads := make(map[string]Thread, len(db.threads))
+	for id, thread := range db.threads {
+		threads[id] = thread
+	}
+	return threads
 }
 
 func (db *Db) GetThreads(ctx context.Context) []Thread {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
 	t := []Thread{}
+	// there is no error to report, a canceled call lists nothing
+	if err := checkContext(ctx); err != nil {
+		return t
+	}
 	for _, thread := range db.threads {
 		t = append(t, thread)
 	}
+	// map iteration is random, keep the insertion order stable for callers
+	sort.Slice(t, func(i, j int) bool {
+		return t[i].Created.Before(t[j].Created)
+	})
 	return t
 }
 
-func (db *Db) AddThread(ctx context.Context, author string, content string) (string, error) {
+func (db *Db) ListThreads(ctx context.Context, opts ListOptions) (ThreadPage, error) {
+	if err := checkContext(ctx); err != nil {
+		return ThreadPage{}, err
+	}
+
+	opts, err := opts.normalize()
+	if err != nil {
+		return ThreadPage{}, err
+	}
+	after, err := opts.decodeCursor()
+	if err != nil {
+		return ThreadPage{}, err
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	candidates := db.threads
+	if opts.Tag != "" {
+		// only look at the threads carrying the tag
+		candidates = make(map[string]Thread, len(db.tags[opts.Tag]))
+		for id := range db.tags[opts.Tag] {
+			candidates[id] = db.threads[id]
+		}
+	}
+
+	t := []Thread{}
+	for _, thread := range candidates {
+		if !opts.matches(thread) {
+			continue
+		}
+		if after != nil && !opts.comesAfter(thread, after.Key, after.ID) {
+			continue
+		}
+		t = append(t, thread)
+	}
+	sort.Slice(t, func(i, j int) bool {
+		return opts.less(t[i], t[j])
+	})
+	return opts.newPage(t), nil
+}
+
+func (db *Db) AddThread(ctx context.Context, author string, content string, tags ...string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
 	thread := Thread{
 		ID:         strconv.Itoa(db.increment),
 		Created:    time.Now(),
@@ -60,33 +222,106 @@ func (db *Db) AddThread(ctx context.Context, author string, content string) (str
 		Author:     author,
 		Content:    content,
 		IsEdited:   false,
+		Version:    1,
+		Tags:       normalizeTags(tags),
+	}
+	if _, err := db.record(events.ThreadCreated, thread.ID, thread); err != nil {
+		return Thread{}, err
 	}
-	db.threads[strconv.Itoa(db.increment)] = thread
-	db.increment++
-	return thread.ID, nil
+	db.putThread(thread)
+	db.revisions[thread.ID] = []Revision{newRevision(thread, author)}
+	return thread, nil
 }
 
-func (db *Db) EditThread(ctx context.Context, id string, content string) error {
+// EditThread replaces the content or the tags of the thread and records it
+// as a new revision. When edit.Version is not zero the edit only applies if the
+// thread is still at that version.
+func (db *Db) EditThread(ctx context.Context, id string, edit ThreadEdit) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
 	val, ok := db.threads[id]
 	if !ok {
-		return errors.New("thread is not available")
+		return Thread{}, ErrThreadNotFound
+	}
+	if edit.Version != 0 && val.Version != edit.Version {
+		return Thread{}, ErrVersionMismatch
 	}
 
-	val.Content = content
+	if edit.Content != "" {
+		val.Content = edit.Content
+	}
+	if edit.Tags != nil {
+		val.Tags = normalizeTags(edit.Tags)
+	}
 	val.LastUpdate = time.Now()
 	val.IsEdited = true
+	val.Version++
 
-	db.threads[id] = val
+	if _, err := db.record(events.ThreadEdited, id, val); err != nil {
+		return Thread{}, err
+	}
+	db.putThread(val)
+	db.revisions[id] = append(db.revisions[id], newRevision(val, edit.Editor))
 
-	return nil
+	return val, nil
 }
 
+// DeleteThread moves the thread to the trash, it keeps its revisions until
+// it is purged.
 func (db *Db) DeleteThread(ctx context.Context, id string) error {
-	_, ok := db.threads[id]
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	val, ok := db.threads[id]
 	if !ok {
-		return errors.New("thread is not available")
+		return ErrThreadNotFound
 	}
 
-	delete(db.threads, id)
+	if _, err := db.record(events.ThreadDeleted, id, nil); err != nil {
+		return err
+	}
+	now := time.Now()
+	val.DeletedAt = &now
+	db.putThread(val)
 	return nil
 }
+
+// SearchThreads returns the threads whose content contains every word of
+// query, best match first. A word ending with '*' matches as a prefix.
+func (db *Db) SearchThreads(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	terms := parseQuery(query)
+	hits := db.index.search(terms)
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	results := []SearchResult{}
+	for _, hit := range hits {
+		if len(results) == pageSize(limit) {
+			break
+		}
+		thread, ok := db.threads[hit.id]
+		if !ok {
+			continue
+		}
+		results = append(results, SearchResult{
+			Thread:  thread,
+			Score:   hit.score,
+			Snippet: snippet(thread.Content, terms),
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,310 @@
+package repository
+
+import "strconv"
+
+// dbState is everything a Db holds, it is what the file store snapshots.
+type dbState struct {
+	Increment      int        `json:"increment"`
+	ReplyIncrement int        `json:"reply_increment,omitempty"`
+	Threads        []Thread   `json:"threads"`
+	Trash          []Thread   `json:"trash,omitempty"`
+	Revisions      []Revision `json:"revisions,omitempty"`
+	Replies        []Reply    `json:"replies,omitempty"`
+	Reactions      []Reaction `json:"reactions,omitempty"`
+
+	WebhookIncrement  int        `json:"webhook_increment,omitempty"`
+	DeliveryIncrement int        `json:"delivery_increment,omitempty"`
+	Webhooks          []Webhook  `json:"webhooks,omitempty"`
+	Deliveries        []Delivery `json:"deliveries,omitempty"`
+
+	OutboxIncrement int64         `json:"outbox_increment,omitempty"`
+	Outbox          []OutboxEntry `json:"outbox,omitempty"`
+}
+
+func (db *Db) state() dbState {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	state := dbState{
+		Increment:      db.increment,
+		ReplyIncrement: db.replyIncrement,
+		Threads:        make([]Thread, 0, len(db.threads)),
+		Trash:          make([]Thread, 0, len(db.trash)),
+		Revisions:      []Revision{},
+		Replies:        []Reply{},
+		Reactions:      []Reaction{},
+
+		WebhookIncrement:  db.webhookIncrement,
+		DeliveryIncrement: db.deliveryIncrement,
+		Webhooks:          make([]Webhook, 0, len(db.webhooks)),
+		Deliveries:        make([]Delivery, 0, len(db.deliveries)),
+
+		OutboxIncrement: db.outboxIncrement,
+		Outbox:          make([]OutboxEntry, 0, len(db.outbox)),
+	}
+	for id, thread := range db.threads {
+		state.Threads = append(state.Threads, thread)
+		state.Revisions = append(state.Revisions, db.revisions[id]...)
+		state.Replies = append(state.Replies, db.replies[id]...)
+		state.Reactions = append(state.Reactions, db.reactions[id]...)
+	}
+	for id, thread := range db.trash {
+		state.Trash = append(state.Trash, thread)
+		state.Revisions = append(state.Revisions, db.revisions[id]...)
+		state.Replies = append(state.Replies, db.replies[id]...)
+		state.Reactions = append(state.Reactions, db.reactions[id]...)
+	}
+	for _, webhook := range db.webhooks {
+		state.Webhooks = append(state.Webhooks, webhook)
+	}
+	for _, delivery := range db.deliveries {
+		state.Deliveries = append(state.Deliveries, delivery)
+	}
+	for _, entry := range db.outbox {
+		state.Outbox = append(state.Outbox, entry)
+	}
+	return state
+}
+
+func (db *Db) restore(state dbState) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.increment = state.Increment
+	db.replyIncrement = state.ReplyIncrement
+	db.threads = make(map[string]Thread, len(state.Threads))
+	db.trash = make(map[string]Thread, len(state.Trash))
+	db.revisions = make(map[string][]Revision, len(state.Threads)+len(state.Trash))
+	db.replies = make(map[string][]Reply, len(state.Threads)+len(state.Trash))
+	db.reactions = make(map[string][]Reaction, len(state.Threads)+len(state.Trash))
+	db.tags = make(map[string]map[string]bool)
+	db.webhookIncrement = state.WebhookIncrement
+	db.deliveryIncrement = state.DeliveryIncrement
+	db.webhooks = make(map[string]Webhook, len(state.Webhooks))
+	db.deliveries = make(map[string]Delivery, len(state.Deliveries))
+	db.outboxIncrement = state.OutboxIncrement
+	db.outbox = make(map[int64]OutboxEntry, len(state.Outbox))
+	db.index = newSearchIndex()
+	for _, thread := range state.Threads {
+		db.putThread(thread)
+	}
+	for _, thread := range state.Trash {
+		db.putThread(thread)
+	}
+	for _, revision := range state.Revisions {
+		db.putRevision(revision)
+	}
+	for _, reply := range state.Replies {
+		db.setReply(reply)
+	}
+	for _, reaction := range state.Reactions {
+		db.setReaction(reaction)
+	}
+	for _, webhook := range state.Webhooks {
+		db.setWebhook(webhook)
+	}
+	for _, delivery := range state.Deliveries {
+		db.setDelivery(delivery)
+	}
+	for _, entry := range state.Outbox {
+		db.setOutbox(entry)
+	}
+}
+
+// put stores a thread as-is along with revisions of it, it is used to
+// rebuild the state from persisted records.
+func (db *Db) put(thread Thread, revisions ...Revision) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(thread)
+	for _, revision := range revisions {
+		db.putRevision(revision)
+	}
+}
+
+// putReply stores a reply as-is, see put.
+func (db *Db) putReply(reply Reply) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.setReply(reply)
+}
+
+// removeReply forgets a reply with the replies below it and stores its
+// thread as it is without them.
+func (db *Db) removeReply(thread Thread, replyID string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(thread)
+	db.unsetReply(thread.ID, replyID)
+}
+
+// putReaction stores thread with its counts and the reaction that changed
+// them, the opposite vote of the user is dropped as AddReaction does.
+func (db *Db) putReaction(thread Thread, reaction Reaction) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(thread)
+	db.unsetReaction(reaction.ThreadID, reaction.Username, opposite(reaction.Kind))
+	db.setReaction(reaction)
+}
+
+// removeReaction stores thread with its counts and forgets the reaction
+// that changed them.
+func (db *Db) removeReaction(thread Thread, reaction Reaction) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(thread)
+	db.unsetReaction(reaction.ThreadID, reaction.Username, reaction.Kind)
+}
+
+func (db *Db) reactionsOf(threadID string) []Reaction {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return append([]Reaction{}, db.reactions[threadID]...)
+}
+
+// resetReactions puts thread and its reactions back after a failed write.
+func (db *Db) resetReactions(thread Thread, reactions []Reaction) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(thread)
+	db.reactions[thread.ID] = reactions
+}
+
+// putThread stores thread in the trash or out of it depending on
+// thread.DeletedAt.
+func (db *Db) putThread(thread Thread) {
+	// records written before versioning and tags
+	if thread.Version == 0 {
+		thread.Version = 1
+	}
+	if thread.Tags == nil {
+		thread.Tags = []string{}
+	}
+
+	if old, ok := db.threads[thread.ID]; ok {
+		db.untag(old)
+	}
+	if thread.DeletedAt != nil {
+		delete(db.threads, thread.ID)
+		db.trash[thread.ID] = thread
+		db.index.delete(thread.ID)
+	} else {
+		delete(db.trash, thread.ID)
+		db.threads[thread.ID] = thread
+		db.index.add(thread.ID, thread.Content)
+		db.tag(thread)
+	}
+	if n, err := strconv.Atoi(thread.ID); err == nil && n >= db.increment {
+		db.increment = n + 1
+	}
+}
+
+// putRevision appends revision unless it is already known, replaying a log
+// over a snapshot sees revisions twice.
+func (db *Db) putRevision(revision Revision) {
+	revisions := db.revisions[revision.ThreadID]
+	if len(revisions) > 0 && revisions[len(revisions)-1].Number >= revision.Number {
+		return
+	}
+	db.revisions[revision.ThreadID] = append(revisions, revision)
+}
+
+// rollback puts old back after a failed write and forgets the revisions
+// made after it.
+func (db *Db) rollback(old Thread) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.putThread(old)
+	revisions := db.revisions[old.ID]
+	for len(revisions) > 0 && revisions[len(revisions)-1].Number > old.Version {
+		revisions = revisions[:len(revisions)-1]
+	}
+	db.revisions[old.ID] = revisions
+}
+
+func (db *Db) remove(id string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if old, ok := db.threads[id]; ok {
+		db.untag(old)
+	}
+	delete(db.threads, id)
+	delete(db.trash, id)
+	delete(db.revisions, id)
+	delete(db.replies, id)
+	delete(db.reactions, id)
+	db.index.delete(id)
+}
+
+// putWebhook stores a webhook as-is, see put.
+func (db *Db) putWebhook(webhook Webhook) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.setWebhook(webhook)
+}
+
+// removeWebhook forgets a webhook with its deliveries.
+func (db *Db) removeWebhook(id string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.unsetWebhook(id)
+}
+
+// putDelivery stores a delivery as-is, see put.
+func (db *Db) putDelivery(delivery Delivery) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.setDelivery(delivery)
+}
+
+func (db *Db) delivery(id string) (Delivery, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	delivery, ok := db.deliveries[id]
+	return delivery, ok
+}
+
+func (db *Db) removeDelivery(id string) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	delete(db.deliveries, id)
+}
+
+// putOutbox stores an entry as-is, see put.
+func (db *Db) putOutbox(entry OutboxEntry) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.setOutbox(entry)
+}
+
+func (db *Db) removeOutbox(ids ...int64) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.unsetOutbox(ids...)
+}
+
+// lastOutbox returns the entry recorded by the last write.
+func (db *Db) lastOutbox() (OutboxEntry, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	entry, ok := db.outbox[db.outboxIncrement-1]
+	return entry, ok
+}
@@ -2,48 +2,124 @@ package repository_test
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"gofiber-api/repository"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/suite"
 )
 
+// threadStore is the contract every thread store implements, the scenarios
+// of DbTestSuite run against each of them.
+type threadStore interface {
+	GetThreadByID(ctx context.Context, id string) (repository.Thread, error)
+	GetThreads(ctx context.Context) []repository.Thread
+	ListThreads(ctx context.Context, opts repository.ListOptions) (repository.ThreadPage, error)
+	SearchThreads(ctx context.Context, query string, limit int) ([]repository.SearchResult, error)
+	AddThread(ctx context.Context, author string, content string, tags ...string) (repository.Thread, error)
+	EditThread(ctx context.Context, id string, edit repository.ThreadEdit) (repository.Thread, error)
+	DeleteThread(ctx context.Context, id string) error
+	GetRevisions(ctx context.Context, id string) ([]repository.Revision, error)
+	GetRevision(ctx context.Context, id string, number int) (repository.Revision, error)
+	GetTrash(ctx context.Context) ([]repository.Thread, error)
+	RestoreThread(ctx context.Context, id string) (repository.Thread, error)
+	PurgeThreads(ctx context.Context, before time.Time) (int, error)
+	GetReplies(ctx context.Context, threadID string) ([]repository.Reply, error)
+	GetReply(ctx context.Context, threadID string, replyID string) (repository.Reply, error)
+	GetReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]repository.Reply, error)
+	AddReply(ctx context.Context, threadID string, parentID string, author string, content string) (repository.Reply, error)
+	EditReply(ctx context.Context, threadID string, replyID string, content string) (repository.Reply, error)
+	DeleteReply(ctx context.Context, threadID string, replyID string) error
+	GetTags(ctx context.Context) ([]repository.TagCount, error)
+	AddReaction(ctx context.Context, threadID string, username string, kind string) (repository.Thread, error)
+	RemoveReaction(ctx context.Context, threadID string, username string, kind string) (repository.Thread, error)
+	GetWebhooks(ctx context.Context) ([]repository.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (repository.Webhook, error)
+	AddWebhook(ctx context.Context, url string, events []string, secret string) (repository.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, webhookID string, status string) ([]repository.Delivery, error)
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]repository.Delivery, error)
+	AddDelivery(ctx context.Context, webhookID string, event string, payload []byte) (repository.Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery repository.Delivery) (repository.Delivery, error)
+	GetOutbox(ctx context.Context, limit int) ([]repository.OutboxEntry, error)
+	AckOutbox(ctx context.Context, ids ...int64) error
+}
+
 type DbTestSuite struct {
 	suite.Suite
-	db repository.Db
+	newStore func(t *testing.T) threadStore
+	db       threadStore
 }
 
 ////////////////////////////
 // trial each suite state //
 ////////////////////////////
 
-// called only in beginning
-func (suite *DbTestSuite) SetupSuite() {
-	suite.db.Init()
-}
-
 // called in every test function
 func (suite *DbTestSuite) SetupTest() {
-	suite.db.Clear()
+	suite.db = suite.newStore(suite.T())
 }
 
 func TestDbTestSuite(t *testing.T) {
-	suite.Run(t, new(DbTestSuite))
+	suite.Run(t, &DbTestSuite{
+		newStore: func(t *testing.T) threadStore {
+			db := &repository.Db{}
+			db.Init()
+			return db
+		},
+	})
+}
+
+func TestFileDbContract(t *testing.T) {
+	suite.Run(t, &DbTestSuite{
+		newStore: func(t *testing.T) threadStore {
+			db, err := repository.OpenFileDb(t.TempDir(), 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	})
+}
+
+func TestSqliteDbContract(t *testing.T) {
+	suite.Run(t, &DbTestSuite{
+		newStore: func(t *testing.T) threadStore {
+			db, err := repository.OpenSqliteDb(t.TempDir() + "/threads.db")
+			if err != nil {
+				t.Fatal(err)
+			}
+			t.Cleanup(func() { db.Close() })
+			return db
+		},
+	})
 }
 
 ////////////////////////////
 
+func (s *DbTestSuite) edit(id string, content string) {
+	_, err := s.db.EditThread(context.Background(), id, repository.ThreadEdit{Content: content})
+	s.Require().NoError(err)
+}
+
 func (s *DbTestSuite) TestCreateThread() {
-	id, err := s.db.AddThread(context.Background(), "the-author", "the-content")
+	added, err := s.db.AddThread(context.Background(), "the-author", "the-content")
 	s.Nil(err)
 
-	thread, err := s.db.GetThreadByID(id)
+	// the thread returned is the one stored
+	thread, err := s.db.GetThreadByID(context.Background(), added.ID)
 	s.Nil(err)
+	s.Equal(added, thread)
 	s.Equal("the-author", thread.Author)
 	s.Equal("the-content", thread.Content)
 	s.NotEmpty(thread.Created)
 	s.NotEmpty(thread.LastUpdate)
 	s.False(thread.IsEdited)
+	s.Equal(1, thread.Version)
 }
 
 func (s *DbTestSuite) TestGetThread() {
@@ -76,8 +152,10 @@ func (s *DbTestSuite) TestEditThread() {
 
 	threads := s.db.GetThreads(context.Background())
 	// edit thread
-	err := s.db.EditThread(context.Background(), threads[0].ID, "the edited content")
+	thread, err := s.db.EditThread(context.Background(), threads[0].ID, repository.ThreadEdit{Content: "the edited content"})
 	s.NoError(err)
+	s.Equal("the edited content", thread.Content)
+	s.Equal(2, thread.Version)
 
 	threads = s.db.GetThreads(context.Background())
 	s.NotEmpty(threads)
@@ -102,7 +180,882 @@ func (s *DbTestSuite) TestDeleteThread() {
 	s.NotEmpty(threads)
 	s.Equal(2, len(threads))
 
-	thread, err := s.db.GetThreadByID("1")
+	thread, err := s.db.GetThreadByID(context.Background(), "1")
 	s.Empty(thread)
 	s.Error(err)
 }
+
+func (s *DbTestSuite) TestCanceledContext() {
+	added, err := s.db.AddThread(context.Background(), "the-author", "the content")
+	s.Require().NoError(err)
+	id := added.ID
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = s.db.GetThreadByID(ctx, id)
+	s.ErrorIs(err, repository.ErrCanceled)
+	s.ErrorIs(err, context.Canceled)
+	_, err = s.db.AddThread(ctx, "the-author", "the other content")
+	s.ErrorIs(err, repository.ErrCanceled)
+	_, err = s.db.EditThread(ctx, id, repository.ThreadEdit{Content: "the edited content"})
+	s.ErrorIs(err, repository.ErrCanceled)
+	s.ErrorIs(s.db.DeleteThread(ctx, id), repository.ErrCanceled)
+	_, err = s.db.AddReply(ctx, id, "", "the-replier", "the reply")
+	s.ErrorIs(err, repository.ErrCanceled)
+	s.Empty(s.db.GetThreads(ctx))
+
+	// a deadline is told apart from a cancellation
+	ctx, cancel = context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = s.db.ListThreads(ctx, repository.ListOptions{})
+	s.ErrorIs(err, repository.ErrCanceled)
+	s.ErrorIs(err, context.DeadlineExceeded)
+
+	// nothing was changed
+	thread, err := s.db.GetThreadByID(context.Background(), id)
+	s.NoError(err)
+	s.Equal("the content", thread.Content)
+	s.Equal(0, thread.ReplyCount)
+	s.Equal(1, len(s.db.GetThreads(context.Background())))
+	entries, err := s.db.GetOutbox(context.Background(), 0)
+	s.NoError(err)
+	s.Equal(1, len(entries))
+}
+
+func (s *DbTestSuite) TestListThreadsPagination() {
+	for i := 0; i < 5; i++ {
+		s.db.AddThread(context.Background(), "the-author", "the content")
+	}
+	// an edit moves the thread to the front
+	s.edit("1", "the edited content")
+
+	ids := []string{}
+	cursor := ""
+	pages := 0
+	for {
+		page, err := s.db.ListThreads(context.Background(), repository.ListOptions{
+			Limit:  2,
+			Cursor: cursor,
+		})
+		s.Require().NoError(err)
+		s.LessOrEqual(len(page.Threads), 2)
+		for _, thread := range page.Threads {
+			ids = append(ids, thread.ID)
+		}
+		pages++
+
+		if page.NextCursor == "" {
+			break
+		}
+		cursor = page.NextCursor
+	}
+
+	s.Equal(3, pages)
+	s.Equal([]string{"1", "4", "3", "2", "0"}, ids)
+}
+
+func (s *DbTestSuite) TestListThreadsInvalidCursor() {
+	_, err := s.db.ListThreads(context.Background(), repository.ListOptions{Cursor: "not a cursor"})
+	s.ErrorIs(err, repository.ErrInvalidCursor)
+}
+
+func (s *DbTestSuite) TestListThreadsFilters() {
+	s.db.AddThread(context.Background(), "alice", "the content 0")
+	s.db.AddThread(context.Background(), "bob", "the content 1")
+	s.db.AddThread(context.Background(), "alice", "the content 2")
+	s.edit("2", "the edited content")
+
+	ids := func(opts repository.ListOptions) []string {
+		page, err := s.db.ListThreads(context.Background(), opts)
+		s.Require().NoError(err)
+		ids := []string{}
+		for _, thread := range page.Threads {
+			ids = append(ids, thread.ID)
+		}
+		return ids
+	}
+
+	edited, notEdited := true, false
+	s.Equal([]string{"2", "0"}, ids(repository.ListOptions{Author: "alice"}))
+	s.Equal([]string{"2"}, ids(repository.ListOptions{Edited: &edited}))
+	s.Equal([]string{"0"}, ids(repository.ListOptions{Author: "alice", Edited: &notEdited}))
+	s.Empty(ids(repository.ListOptions{Author: "carol"}))
+
+	second, err := s.db.GetThreadByID(context.Background(), "1")
+	s.Require().NoError(err)
+	s.Equal([]string{"2"}, ids(repository.ListOptions{CreatedAfter: second.Created}))
+	s.Equal([]string{"0"}, ids(repository.ListOptions{CreatedBefore: second.Created}))
+}
+
+func (s *DbTestSuite) TestListThreadsSort() {
+	s.db.AddThread(context.Background(), "carol", "the content 0")
+	s.db.AddThread(context.Background(), "alice", "the content 1")
+	s.db.AddThread(context.Background(), "bob", "the content 2")
+	s.db.AddThread(context.Background(), "alice", "the content 3")
+	s.edit("0", "the edited content")
+
+	ids := func(opts repository.ListOptions) []string {
+		ids := []string{}
+		for {
+			page, err := s.db.ListThreads(context.Background(), opts)
+			s.Require().NoError(err)
+			for _, thread := range page.Threads {
+				ids = append(ids, thread.ID)
+			}
+			if page.NextCursor == "" {
+				return ids
+			}
+			opts.Cursor = page.NextCursor
+		}
+	}
+
+	s.Equal([]string{"0", "3", "2", "1"}, ids(repository.ListOptions{Limit: 3}))
+	s.Equal([]string{"1", "2", "3", "0"}, ids(repository.ListOptions{Limit: 3, Order: repository.OrderAsc}))
+	s.Equal([]string{"3", "2", "1", "0"}, ids(repository.ListOptions{Limit: 3, Sort: repository.SortCreated}))
+	s.Equal([]string{"0", "1", "2", "3"}, ids(repository.ListOptions{Limit: 1, Sort: repository.SortCreated, Order: repository.OrderAsc}))
+	s.Equal([]string{"1", "3", "2", "0"}, ids(repository.ListOptions{Limit: 1, Sort: repository.SortAuthor, Order: repository.OrderAsc}))
+	s.Equal([]string{"0", "2", "3", "1"}, ids(repository.ListOptions{Limit: 2, Sort: repository.SortAuthor}))
+
+	_, err := s.db.ListThreads(context.Background(), repository.ListOptions{Sort: "content"})
+	s.ErrorIs(err, repository.ErrInvalidSort)
+
+	// a cursor only continues the ordering it was issued for
+	page, err := s.db.ListThreads(context.Background(), repository.ListOptions{Limit: 1})
+	s.Require().NoError(err)
+	_, err = s.db.ListThreads(context.Background(), repository.ListOptions{Cursor: page.NextCursor, Sort: repository.SortAuthor})
+	s.ErrorIs(err, repository.ErrInvalidCursor)
+}
+
+func (s *DbTestSuite) TestSearchThreads() {
+	s.db.AddThread(context.Background(), "the-author", "Gophers love concurrency")
+	s.db.AddThread(context.Background(), "the-author", "Concurrency is not parallelism, concurrency is structure")
+	s.db.AddThread(context.Background(), "the-author", "Rust has no gophers")
+
+	ids := func(query string) []string {
+		results, err := s.db.SearchThreads(context.Background(), query, 0)
+		s.Require().NoError(err)
+		ids := []string{}
+		for _, result := range results {
+			ids = append(ids, result.Thread.ID)
+		}
+		return ids
+	}
+
+	// the thread repeating the word ranks first
+	s.Equal([]string{"1", "0"}, ids("concurrency"))
+	s.Equal([]string{"0"}, ids("GOPHERS concurrency"))
+	s.Equal([]string{"0", "2"}, ids("gopher*"))
+	s.Equal([]string{"1"}, ids("para* struct*"))
+	s.Empty(ids("gopher"))
+	s.Empty(ids(""))
+
+	// the index follows edits and deletes
+	s.edit("2", "Rust has crabs")
+	s.Equal([]string{"0"}, ids("gophers"))
+	s.Equal([]string{"2"}, ids("crabs"))
+	s.NoError(s.db.DeleteThread(context.Background(), "1"))
+	s.Equal([]string{"0"}, ids("concurrency"))
+
+	results, err := s.db.SearchThreads(context.Background(), "gophers", 0)
+	s.Require().NoError(err)
+	s.Equal("<mark>Gophers</mark> love concurrency", results[0].Snippet)
+	s.Greater(results[0].Score, 0.0)
+}
+
+func (s *DbTestSuite) TestMissingThreadErrors() {
+	_, err := s.db.GetThreadByID(context.Background(), "42")
+	s.ErrorIs(err, repository.ErrNotFound)
+	_, err = s.db.EditThread(context.Background(), "42", repository.ThreadEdit{Content: "the content"})
+	s.ErrorIs(err, repository.ErrNotFound)
+	s.ErrorIs(s.db.DeleteThread(context.Background(), "42"), repository.ErrNotFound)
+}
+
+func (s *DbTestSuite) TestEditThreadVersion() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+
+	thread, err := s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "first edit", Version: 1})
+	s.NoError(err)
+	s.Equal(2, thread.Version)
+
+	// a writer still holding version 1 lost the race
+	_, err = s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "stale edit", Version: 1})
+	s.ErrorIs(err, repository.ErrPreconditionFailed)
+
+	thread, err = s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal("first edit", thread.Content)
+	s.Equal(2, thread.Version)
+
+	thread, err = s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "unconditional edit"})
+	s.NoError(err)
+	s.Equal(3, thread.Version)
+}
+
+func (s *DbTestSuite) TestConcurrentVersionedEdits() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	succeeded := 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "racing edit", Version: 1}); err == nil {
+				mu.Lock()
+				succeeded++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	s.Equal(1, succeeded)
+}
+
+func (s *DbTestSuite) TestRevisions() {
+	s.db.AddThread(context.Background(), "the-author", "the first content")
+	_, err := s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "the second content", Editor: "the-author"})
+	s.NoError(err)
+	thread, err := s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "the third content", Editor: "the-moderator"})
+	s.NoError(err)
+
+	revisions, err := s.db.GetRevisions(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(3, len(revisions))
+	for i, content := range []string{"the first content", "the second content", "the third content"} {
+		s.Equal(i+1, revisions[i].Number)
+		s.Equal(content, revisions[i].Content)
+		s.Equal("0", revisions[i].ThreadID)
+		s.NotEmpty(revisions[i].Created)
+	}
+	s.Equal("the-author", revisions[0].Editor)
+	s.Equal("the-moderator", revisions[2].Editor)
+	s.True(thread.LastUpdate.Equal(revisions[2].Created))
+
+	revision, err := s.db.GetRevision(context.Background(), "0", 2)
+	s.NoError(err)
+	s.Equal("the second content", revision.Content)
+
+	_, err = s.db.GetRevision(context.Background(), "0", 4)
+	s.ErrorIs(err, repository.ErrRevisionNotFound)
+
+	s.NoError(s.db.DeleteThread(context.Background(), "0"))
+	_, err = s.db.GetRevisions(context.Background(), "0")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	_, err = s.db.GetRevision(context.Background(), "0", 1)
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+}
+
+func (s *DbTestSuite) TestTrash() {
+	s.db.AddThread(context.Background(), "the-author", "the searchable content")
+	s.db.AddThread(context.Background(), "the-author-2", "the content 2")
+	s.edit("0", "the searchable edited content")
+
+	s.NoError(s.db.DeleteThread(context.Background(), "0"))
+	s.ErrorIs(s.db.DeleteThread(context.Background(), "0"), repository.ErrThreadNotFound)
+
+	// a trashed thread is gone for every read and write
+	s.Equal(1, len(s.db.GetThreads(context.Background())))
+	page, err := s.db.ListThreads(context.Background(), repository.ListOptions{})
+	s.NoError(err)
+	s.Equal(1, len(page.Threads))
+	results, err := s.db.SearchThreads(context.Background(), "searchable", 0)
+	s.NoError(err)
+	s.Empty(results)
+	_, err = s.db.GetThreadByID(context.Background(), "0")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	_, err = s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "the new content"})
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+
+	trash, err := s.db.GetTrash(context.Background())
+	s.NoError(err)
+	s.Equal(1, len(trash))
+	s.Equal("0", trash[0].ID)
+	s.NotNil(trash[0].DeletedAt)
+
+	_, err = s.db.RestoreThread(context.Background(), "1")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+
+	thread, err := s.db.RestoreThread(context.Background(), "0")
+	s.NoError(err)
+	s.Nil(thread.DeletedAt)
+	s.Equal("the searchable edited content", thread.Content)
+	s.Equal(2, thread.Version)
+
+	revisions, err := s.db.GetRevisions(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(2, len(revisions))
+	results, err = s.db.SearchThreads(context.Background(), "searchable", 0)
+	s.NoError(err)
+	s.Equal(1, len(results))
+
+	trash, err = s.db.GetTrash(context.Background())
+	s.NoError(err)
+	s.Empty(trash)
+}
+
+func (s *DbTestSuite) TestPurgeThreads() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+	s.db.AddThread(context.Background(), "the-author-2", "the content 2")
+	s.db.AddThread(context.Background(), "the-author-3", "the content 3")
+
+	s.NoError(s.db.DeleteThread(context.Background(), "0"))
+	cutoff := time.Now()
+	time.Sleep(time.Millisecond)
+	s.NoError(s.db.DeleteThread(context.Background(), "1"))
+
+	n, err := s.db.PurgeThreads(context.Background(), cutoff.Add(-time.Hour))
+	s.NoError(err)
+	s.Zero(n)
+
+	n, err = s.db.PurgeThreads(context.Background(), cutoff)
+	s.NoError(err)
+	s.Equal(1, n)
+
+	trash, err := s.db.GetTrash(context.Background())
+	s.NoError(err)
+	s.Equal(1, len(trash))
+	s.Equal("1", trash[0].ID)
+
+	_, err = s.db.RestoreThread(context.Background(), "0")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	s.Equal(1, len(s.db.GetThreads(context.Background())))
+}
+
+func (s *DbTestSuite) TestReplies() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+	s.db.AddThread(context.Background(), "the-author-2", "the content 2")
+
+	first, err := s.db.AddReply(context.Background(), "0", "", "the-replier", "the first reply")
+	s.NoError(err)
+	s.Equal("0", first.ThreadID)
+	s.NotEmpty(first.Created)
+	second, err := s.db.AddReply(context.Background(), "0", "", "the-replier-2", "the second reply")
+	s.NoError(err)
+	s.NotEqual(first.ID, second.ID)
+	_, err = s.db.AddReply(context.Background(), "1", "", "the-replier", "the other reply")
+	s.NoError(err)
+	_, err = s.db.AddReply(context.Background(), "2", "", "the-replier", "the lost reply")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+
+	// replies do not edit their thread
+	thread, err := s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(2, thread.ReplyCount)
+	s.Equal(1, thread.Version)
+	s.False(thread.IsEdited)
+	page, err := s.db.ListThreads(context.Background(), repository.ListOptions{Sort: repository.SortCreated, Order: repository.OrderAsc})
+	s.NoError(err)
+	s.Equal(2, page.Threads[0].ReplyCount)
+	s.Equal(1, page.Threads[1].ReplyCount)
+
+	reply, err := s.db.EditReply(context.Background(), "0", first.ID, "the edited reply")
+	s.NoError(err)
+	s.True(reply.IsEdited)
+	_, err = s.db.EditReply(context.Background(), "1", first.ID, "the misplaced reply")
+	s.ErrorIs(err, repository.ErrReplyNotFound)
+
+	replies, err := s.db.GetReplies(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(2, len(replies))
+	s.Equal("the edited reply", replies[0].Content)
+	s.Equal("the second reply", replies[1].Content)
+
+	s.NoError(s.db.DeleteReply(context.Background(), "0", first.ID))
+	s.ErrorIs(s.db.DeleteReply(context.Background(), "0", first.ID), repository.ErrReplyNotFound)
+	_, err = s.db.GetReply(context.Background(), "0", first.ID)
+	s.ErrorIs(err, repository.ErrReplyNotFound)
+	thread, err = s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, thread.ReplyCount)
+}
+
+func (s *DbTestSuite) TestRepliesFollowTheirThread() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+	reply, err := s.db.AddReply(context.Background(), "0", "", "the-replier", "the reply")
+	s.NoError(err)
+
+	s.NoError(s.db.DeleteThread(context.Background(), "0"))
+	_, err = s.db.GetReplies(context.Background(), "0")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	_, err = s.db.AddReply(context.Background(), "0", "", "the-replier", "the late reply")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	s.ErrorIs(s.db.DeleteReply(context.Background(), "0", reply.ID), repository.ErrThreadNotFound)
+
+	_, err = s.db.RestoreThread(context.Background(), "0")
+	s.NoError(err)
+	replies, err := s.db.GetReplies(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, len(replies))
+
+	s.NoError(s.db.DeleteThread(context.Background(), "0"))
+	n, err := s.db.PurgeThreads(context.Background(), time.Now().Add(time.Second))
+	s.NoError(err)
+	s.Equal(1, n)
+	_, err = s.db.RestoreThread(context.Background(), "0")
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+}
+
+func (s *DbTestSuite) reply(threadID string, parentID string, content string) repository.Reply {
+	reply, err := s.db.AddReply(context.Background(), threadID, parentID, "the-replier", content)
+	s.Require().NoError(err)
+	return reply
+}
+
+func (s *DbTestSuite) TestReplyTree() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+	s.db.AddThread(context.Background(), "the-author-2", "the content 2")
+
+	a := s.reply("0", "", "a")
+	ab := s.reply("0", a.ID, "a.b")
+	abc := s.reply("0", ab.ID, "a.b.c")
+	s.reply("0", "", "d")
+	ae := s.reply("0", a.ID, "a.e")
+	s.Equal(1, a.Depth)
+	s.Equal(2, ab.Depth)
+	s.Equal(3, abc.Depth)
+	s.Equal(a.ID, ae.ParentID)
+
+	_, err := s.db.AddReply(context.Background(), "0", "404", "the-replier", "the orphan")
+	s.ErrorIs(err, repository.ErrParentNotFound)
+	// a parent must belong to the same thread
+	_, err = s.db.AddReply(context.Background(), "1", a.ID, "the-replier", "the stray")
+	s.ErrorIs(err, repository.ErrParentNotFound)
+
+	replies, err := s.db.GetReplyTree(context.Background(), "0", "", 0)
+	s.NoError(err)
+	tree := repository.BuildReplyTree(replies)
+	s.Equal(2, len(tree))
+	s.Equal("a", tree[0].Content)
+	s.Equal("d", tree[1].Content)
+	s.Equal(2, len(tree[0].Replies))
+	s.Equal("a.b", tree[0].Replies[0].Content)
+	s.Equal("a.e", tree[0].Replies[1].Content)
+	s.Equal("a.b.c", tree[0].Replies[0].Replies[0].Content)
+	s.Empty(tree[1].Replies)
+
+	replies, err = s.db.GetReplyTree(context.Background(), "0", "", 2)
+	s.NoError(err)
+	s.Equal(4, len(replies))
+
+	replies, err = s.db.GetReplyTree(context.Background(), "0", ab.ID, 0)
+	s.NoError(err)
+	s.Equal(2, len(replies))
+	tree = repository.BuildReplyTree(replies)
+	s.Equal(1, len(tree))
+	s.Equal("a.b", tree[0].Content)
+	s.Equal("a.b.c", tree[0].Replies[0].Content)
+
+	replies, err = s.db.GetReplyTree(context.Background(), "0", a.ID, 1)
+	s.NoError(err)
+	s.Equal(1, len(replies))
+
+	_, err = s.db.GetReplyTree(context.Background(), "0", "404", 0)
+	s.ErrorIs(err, repository.ErrReplyNotFound)
+
+	// deleting a reply takes its subtree with it
+	s.NoError(s.db.DeleteReply(context.Background(), "0", a.ID))
+	replies, err = s.db.GetReplies(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, len(replies))
+	s.Equal("d", replies[0].Content)
+	thread, err := s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, thread.ReplyCount)
+}
+
+func (s *DbTestSuite) TestReactions() {
+	s.db.AddThread(context.Background(), "the-author", "the content")
+
+	react := func(username string, kind string) repository.Thread {
+		thread, err := s.db.AddReaction(context.Background(), "0", username, kind)
+		s.Require().NoError(err)
+		return thread
+	}
+
+	thread := react("user-1", repository.ReactionLike)
+	s.Equal(1, thread.Reactions[repository.ReactionLike])
+	s.Equal(1, thread.Score)
+
+	// reacting twice is a no-op
+	thread = react("user-1", repository.ReactionLike)
+	s.Equal(1, thread.Reactions[repository.ReactionLike])
+
+	react("user-1", repository.ReactionUpvote)
+	react("user-2", repository.ReactionHeart)
+	thread = react("user-2", repository.ReactionDownvote)
+	s.Equal(1, thread.Reactions[repository.ReactionDownvote])
+	s.Equal(1, thread.Score)
+
+	// a vote replaces the opposite one
+	thread = react("user-2", repository.ReactionUpvote)
+	s.Equal(0, thread.Reactions[repository.ReactionDownvote])
+	s.Equal(2, thread.Reactions[repository.ReactionUpvote])
+	s.Equal(3, thread.Score)
+
+	thread, err := s.db.RemoveReaction(context.Background(), "0", "user-1", repository.ReactionLike)
+	s.NoError(err)
+	s.Equal(0, thread.Reactions[repository.ReactionLike])
+	s.Equal(2, thread.Score)
+	thread, err = s.db.RemoveReaction(context.Background(), "0", "user-1", repository.ReactionLike)
+	s.NoError(err)
+	s.Equal(2, thread.Score)
+
+	// reactions are not edits
+	thread, err = s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(1, thread.Version)
+	s.Equal(1, thread.Reactions[repository.ReactionHeart])
+	s.Equal(2, thread.Score)
+
+	_, err = s.db.AddReaction(context.Background(), "1", "user-1", repository.ReactionLike)
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+	_, err = s.db.RemoveReaction(context.Background(), "1", "user-1", repository.ReactionLike)
+	s.ErrorIs(err, repository.ErrThreadNotFound)
+}
+
+func (s *DbTestSuite) TestListThreadsTop() {
+	for i, votes := range []int{1, 3, 0, 3, 2} {
+		added, err := s.db.AddThread(context.Background(), "the-author", fmt.Sprintf("the content %d", i))
+		s.Require().NoError(err)
+		id := added.ID
+		for v := 0; v < votes; v++ {
+			_, err := s.db.AddReaction(context.Background(), id, fmt.Sprintf("user-%d", v), repository.ReactionUpvote)
+			s.Require().NoError(err)
+		}
+	}
+
+	ids := []string{}
+	opts := repository.ListOptions{Limit: 2, Sort: repository.SortTop}
+	for {
+		page, err := s.db.ListThreads(context.Background(), opts)
+		s.Require().NoError(err)
+		for _, thread := range page.Threads {
+			ids = append(ids, thread.ID)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		opts.Cursor = page.NextCursor
+	}
+	// ties are broken by id in the same direction
+	s.Equal([]string{"3", "1", "4", "0", "2"}, ids)
+}
+
+func (s *DbTestSuite) TestTags() {
+	s.db.AddThread(context.Background(), "the-author", "the content", "Go", "web", "go ")
+	s.db.AddThread(context.Background(), "the-author", "the content 2", "go")
+	s.db.AddThread(context.Background(), "the-author", "the content 3")
+
+	thread, err := s.db.GetThreadByID(context.Background(), "0")
+	s.NoError(err)
+	s.Equal([]string{"go", "web"}, thread.Tags)
+	thread, err = s.db.GetThreadByID(context.Background(), "2")
+	s.NoError(err)
+	s.Equal([]string{}, thread.Tags)
+
+	tags, err := s.db.GetTags(context.Background())
+	s.NoError(err)
+	s.Equal([]repository.TagCount{{Tag: "go", Count: 2}, {Tag: "web", Count: 1}}, tags)
+
+	page, err := s.db.ListThreads(context.Background(), repository.ListOptions{Tag: "GO", Limit: 1, Sort: repository.SortCreated})
+	s.NoError(err)
+	s.Equal(1, len(page.Threads))
+	s.Equal("1", page.Threads[0].ID)
+	page, err = s.db.ListThreads(context.Background(), repository.ListOptions{Tag: "go", Limit: 1, Sort: repository.SortCreated, Cursor: page.NextCursor})
+	s.NoError(err)
+	s.Equal(1, len(page.Threads))
+	s.Equal("0", page.Threads[0].ID)
+	s.Empty(page.NextCursor)
+
+	// an edit without content only changes the tags
+	thread, err = s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Tags: []string{"web", "news"}})
+	s.NoError(err)
+	s.Equal("the content", thread.Content)
+	s.Equal([]string{"news", "web"}, thread.Tags)
+	s.Equal(2, thread.Version)
+
+	// and an edit without tags keeps them
+	thread, err = s.db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "the new content"})
+	s.NoError(err)
+	s.Equal([]string{"news", "web"}, thread.Tags)
+
+	tags, err = s.db.GetTags(context.Background())
+	s.NoError(err)
+	s.Equal([]repository.TagCount{{Tag: "go", Count: 1}, {Tag: "news", Count: 1}, {Tag: "web", Count: 1}}, tags)
+
+	// trashed threads are not counted nor listed
+	s.NoError(s.db.DeleteThread(context.Background(), "1"))
+	page, err = s.db.ListThreads(context.Background(), repository.ListOptions{Tag: "go"})
+	s.NoError(err)
+	s.Empty(page.Threads)
+	tags, err = s.db.GetTags(context.Background())
+	s.NoError(err)
+	s.Equal(2, len(tags))
+
+	_, err = s.db.RestoreThread(context.Background(), "1")
+	s.NoError(err)
+	page, err = s.db.ListThreads(context.Background(), repository.ListOptions{Tag: "go"})
+	s.NoError(err)
+	s.Equal(1, len(page.Threads))
+}
+
+// run with `go test -race` to let the race detector verify the locking
+func (s *DbTestSuite) TestWebhooks() {
+	ctx := context.Background()
+
+	first, err := s.db.AddWebhook(ctx, "http://example.com/hook", []string{"created", "deleted"}, "the-secret")
+	s.Require().NoError(err)
+	s.Equal("the-secret", first.Secret)
+	s.True(first.Subscribes("created"))
+	s.False(first.Subscribes("edited"))
+	second, err := s.db.AddWebhook(ctx, "http://example.com/other", []string{"edited"}, "other-secret")
+	s.Require().NoError(err)
+	s.NotEqual(first.ID, second.ID)
+
+	webhooks, err := s.db.GetWebhooks(ctx)
+	s.NoError(err)
+	s.Equal([]string{first.ID, second.ID}, []string{webhooks[0].ID, webhooks[1].ID})
+
+	webhook, err := s.db.GetWebhook(ctx, first.ID)
+	s.NoError(err)
+	s.Equal("http://example.com/hook", webhook.URL)
+	s.Equal([]string{"created", "deleted"}, webhook.Events)
+
+	// the deliveries go with their webhook
+	_, err = s.db.AddDelivery(ctx, first.ID, "created", []byte(`{}`))
+	s.Require().NoError(err)
+	s.NoError(s.db.DeleteWebhook(ctx, first.ID))
+
+	_, err = s.db.GetWebhook(ctx, first.ID)
+	s.ErrorIs(err, repository.ErrWebhookNotFound)
+	s.ErrorIs(s.db.DeleteWebhook(ctx, first.ID), repository.ErrWebhookNotFound)
+	_, err = s.db.GetDeliveries(ctx, first.ID, "")
+	s.ErrorIs(err, repository.ErrWebhookNotFound)
+	deliveries, err := s.db.GetDeliveries(ctx, "", "")
+	s.NoError(err)
+	s.Empty(deliveries)
+}
+
+func (s *DbTestSuite) TestDeliveries() {
+	ctx := context.Background()
+
+	_, err := s.db.AddDelivery(ctx, "missing", "created", []byte(`{}`))
+	s.ErrorIs(err, repository.ErrWebhookNotFound)
+
+	webhook, err := s.db.AddWebhook(ctx, "http://example.com/hook", []string{"created"}, "the-secret")
+	s.Require().NoError(err)
+	first, err := s.db.AddDelivery(ctx, webhook.ID, "created", []byte(`{"thread_id":"0"}`))
+	s.Require().NoError(err)
+	s.Equal(repository.DeliveryPending, first.Status)
+	s.JSONEq(`{"thread_id":"0"}`, string(first.Payload))
+	second, err := s.db.AddDelivery(ctx, webhook.ID, "created", []byte(`{"thread_id":"1"}`))
+	s.Require().NoError(err)
+
+	due, err := s.db.DueDeliveries(ctx, time.Now(), 10)
+	s.NoError(err)
+	s.Equal([]string{first.ID, second.ID}, []string{due[0].ID, due[1].ID})
+
+	// a failed attempt is retried later, the other one is given up on
+	first.Attempts = 1
+	first.NextAttempt = time.Now().Add(time.Hour)
+	first.ResponseStatus = 500
+	first.LastError = "unexpected status 500"
+	updated, err := s.db.UpdateDelivery(ctx, first)
+	s.NoError(err)
+	s.Equal(1, updated.Attempts)
+	s.Equal(500, updated.ResponseStatus)
+	s.JSONEq(`{"thread_id":"0"}`, string(updated.Payload))
+
+	second.Attempts = 3
+	second.Status = repository.DeliveryDead
+	_, err = s.db.UpdateDelivery(ctx, second)
+	s.NoError(err)
+
+	due, err = s.db.DueDeliveries(ctx, time.Now(), 10)
+	s.NoError(err)
+	s.Empty(due)
+	due, err = s.db.DueDeliveries(ctx, time.Now().Add(2*time.Hour), 10)
+	s.NoError(err)
+	s.Len(due, 1)
+	s.Equal(first.ID, due[0].ID)
+
+	deliveries, err := s.db.GetDeliveries(ctx, webhook.ID, "")
+	s.NoError(err)
+	s.Len(deliveries, 2)
+	dead, err := s.db.GetDeliveries(ctx, "", repository.DeliveryDead)
+	s.NoError(err)
+	s.Len(dead, 1)
+	s.Equal(second.ID, dead[0].ID)
+	s.Equal(3, dead[0].Attempts)
+
+	_, err = s.db.UpdateDelivery(ctx, repository.Delivery{ID: "missing", Status: repository.DeliveryDead})
+	s.ErrorIs(err, repository.ErrDeliveryNotFound)
+}
+
+func (s *DbTestSuite) TestOutbox() {
+	ctx := context.Background()
+
+	added, err := s.db.AddThread(ctx, "the-author", "the content", "news")
+	s.Require().NoError(err)
+	id := added.ID
+	s.edit(id, "the edited content")
+	reply, err := s.db.AddReply(ctx, id, "", "the-replier", "the reply")
+	s.Require().NoError(err)
+	s.Require().NoError(s.db.DeleteThread(ctx, id))
+
+	// failed writes record nothing
+	_, err = s.db.EditThread(ctx, "missing", repository.ThreadEdit{Content: "the content"})
+	s.Error(err)
+	s.Error(s.db.DeleteThread(ctx, id))
+
+	entries, err := s.db.GetOutbox(ctx, 0)
+	s.NoError(err)
+	s.Require().Equal(4, len(entries))
+	types := []string{}
+	keys := map[string]bool{}
+	for i, entry := range entries {
+		s.Equal(id, entry.ThreadID)
+		s.NotEmpty(entry.Key)
+		keys[entry.Key] = true
+		if i > 0 {
+			s.Greater(entry.ID, entries[i-1].ID)
+		}
+		types = append(types, entry.Type)
+	}
+	s.Equal([]string{"created", "edited", "reply_created", "deleted"}, types)
+	s.Equal(4, len(keys))
+
+	var thread repository.Thread
+	s.NoError(json.Unmarshal(entries[1].Data, &thread))
+	s.Equal("the edited content", thread.Content)
+	s.Equal([]string{"news"}, thread.Tags)
+	var replied repository.Reply
+	s.NoError(json.Unmarshal(entries[2].Data, &replied))
+	s.Equal(reply.ID, replied.ID)
+	s.Empty(entries[3].Data)
+
+	limited, err := s.db.GetOutbox(ctx, 2)
+	s.NoError(err)
+	s.Equal(entries[:2], limited)
+
+	// acknowledging twice is harmless
+	s.NoError(s.db.AckOutbox(ctx, entries[0].ID, entries[1].ID))
+	s.NoError(s.db.AckOutbox(ctx, entries[0].ID))
+	left, err := s.db.GetOutbox(ctx, 0)
+	s.NoError(err)
+	s.Equal(entries[2:], left)
+}
+
+func (s *DbTestSuite) TestConcurrentAccess() {
+	const workers = 8
+	const perWorker = 25
+
+	var wg sync.WaitGroup
+	ids := make(chan string, workers*perWorker)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				added, err := s.db.AddThread(context.Background(), "the-author", "the content")
+				s.NoError(err)
+				id := added.ID
+				ids <- id
+
+				s.db.EditThread(context.Background(), id, repository.ThreadEdit{Content: "the edited content"})
+				s.db.GetThreadByID(context.Background(), id)
+				s.db.GetThreads(context.Background())
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		s.False(seen[id], "duplicate id %s", id)
+		seen[id] = true
+	}
+	s.Equal(workers*perWorker, len(seen))
+	s.Equal(workers*perWorker, len(s.db.GetThreads(context.Background())))
+
+	// deletes racing with readers must not corrupt the map
+	for id := range seen {
+		wg.Add(2)
+		go func(id string) {
+			defer wg.Done()
+			s.db.DeleteThread(context.Background(), id)
+		}(id)
+		go func() {
+			defer wg.Done()
+			s.db.GetThreads(context.Background())
+		}()
+	}
+	wg.Wait()
+
+	s.Empty(s.db.GetThreads(context.Background()))
+}
+
+func (s *DbTestSuite) TestConcurrentEditsKeepSearchInStep() {
+	ctx := context.Background()
+	added, err := s.db.AddThread(ctx, "the-author", "the content")
+	s.Require().NoError(err)
+	id := added.ID
+
+	words := []string{"alpha", "bravo", "charlie", "delta", "echo", "foxtrot", "golf", "hotel"}
+	for round := 0; round < 10; round++ {
+		var wg sync.WaitGroup
+		for _, word := range words {
+			wg.Add(1)
+			go func(word string) {
+				defer wg.Done()
+				s.db.EditThread(ctx, id, repository.ThreadEdit{Content: word})
+			}(word)
+		}
+		wg.Wait()
+
+		// only the content of the last edit is found
+		thread, err := s.db.GetThreadByID(ctx, id)
+		s.Require().NoError(err)
+		for _, word := range words {
+			results, err := s.db.SearchThreads(ctx, word, 0)
+			s.Require().NoError(err)
+			if word == thread.Content {
+				s.Len(results, 1)
+			} else {
+				s.Empty(results, "%s was replaced by %s", word, thread.Content)
+			}
+		}
+	}
+}
+
+func TestDbClearWhileReading(t *testing.T) {
+	db := &repository.Db{}
+	db.Init()
+	for i := 0; i < 100; i++ {
+		db.AddThread(context.Background(), "the-author", "the content")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db.GetThreadsEntity()
+			db.GetThreads(context.Background())
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		db.Clear()
+	}()
+	wg.Wait()
+
+	if n := len(db.GetThreadsEntity()); n != 0 {
+		t.Fatalf("expected an empty store after clear, got %d threads", n)
+	}
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors returned by the stores are wrapped around one of these sentinels,
+// check them with errors.Is.
+var (
+	ErrNotFound = errors.New("not found")
+	ErrConflict = errors.New("conflict")
+	// ErrPreconditionFailed reports a write based on a stale read
+	ErrPreconditionFailed = errors.New("precondition failed")
+	ErrValidation         = errors.New("validation failed")
+	ErrInternal           = errors.New("internal error")
+	// ErrCanceled reports a call whose context was done before the store
+	// got to it, it wraps the error of the context so a deadline can be
+	// told apart from a cancellation
+	ErrCanceled = errors.New("canceled")
+)
+
+var (
+	ErrThreadNotFound  = fmt.Errorf("thread %w", ErrNotFound)
+	ErrVersionMismatch = fmt.Errorf("%w: thread was modified since it was read", ErrPreconditionFailed)
+)
+
+// ValidationError lists the reasons an input was rejected.
+type ValidationError struct {
+	Fields []string
+}
+
+func NewValidationError(fields ...string) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+func (e *ValidationError) Error() string {
+	return "validation failed: " + strings.Join(e.Fields, ", ")
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// internalError wraps a failure of the underlying storage.
+func internalError(err error) error {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	return fmt.Errorf("%w: %v", ErrInternal, err)
+}
+
+// checkContext returns ErrCanceled once ctx is done.
+func checkContext(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrCanceled, err)
+	}
+	return nil
+}
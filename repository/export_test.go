@@ -0,0 +1,30 @@
+package repository
+
+import (
+	"os"
+	"time"
+)
+
+// StallLog swaps the log of db for a full pipe, the next append blocks until
+// release is called and then fails.
+func (db *FileDb) StallLog() (release func(), err error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := w.SetWriteDeadline(time.Now().Add(10 * time.Millisecond)); err != nil {
+		return nil, err
+	}
+	chunk := make([]byte, 4096)
+	for {
+		if _, err := w.Write(chunk); err != nil {
+			break
+		}
+	}
+	if err := w.SetWriteDeadline(time.Time{}); err != nil {
+		return nil, err
+	}
+
+	db.log = w
+	return func() { r.Close() }, nil
+}
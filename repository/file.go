@@ -0,0 +1,792 @@
+package repository
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	logFileName      = "threads.log"
+	snapshotFileName = "threads.snapshot"
+
+	defaultCompactEvery = 1000
+)
+
+const (
+	opPut            = "put"
+	opDelete         = "delete"
+	opDeleteReply    = "delete_reply"
+	opDeleteReaction = "delete_reaction"
+	opDeleteWebhook  = "delete_webhook"
+	opAckOutbox      = "ack_outbox"
+)
+
+type logRecord struct {
+	Op       string    `json:"op"`
+	Thread   *Thread   `json:"thread,omitempty"`
+	Revision *Revision `json:"revision,omitempty"`
+	Reply    *Reply    `json:"reply,omitempty"`
+	Reaction *Reaction `json:"reaction,omitempty"`
+	Webhook  *Webhook  `json:"webhook,omitempty"`
+	Delivery *Delivery `json:"delivery,omitempty"`
+	// Outbox is the event recorded by the write of the record
+	Outbox *OutboxEntry `json:"outbox,omitempty"`
+	ID     string       `json:"id,omitempty"`
+	Acked  []int64      `json:"acked,omitempty"`
+}
+
+// FileDb is a thread store persisted in a directory. Every mutation is
+// appended to a log and fsynced before it is acknowledged, and the log is
+// folded into a snapshot every compactEvery writes. Reads are served from
+// memory.
+type FileDb struct {
+	mem Db
+
+	// mu serializes mutations so the log order matches the memory order. A
+	// mutation is applied to mem before it is appended and taken back when
+	// the append fails, reads wait for it so they only see what the log
+	// holds.
+	mu           sync.RWMutex
+	dir          string
+	log          *os.File
+	compactEvery int
+	writes       int
+}
+
+// OpenFileDb loads the snapshot and replays the log found in dir, creating
+// the directory when it does not exist. A non positive compactEvery uses the
+// default.
+func OpenFileDb(dir string, compactEvery int) (*FileDb, error) {
+	if compactEvery <= 0 {
+		compactEvery = defaultCompactEvery
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	db := &FileDb{
+		dir:          dir,
+		compactEvery: compactEvery,
+	}
+	db.mem.Init()
+
+	if err := db.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := db.replayLog(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+func (db *FileDb) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(db.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var state dbState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("%w: corrupted snapshot: %v", ErrInternal, err)
+	}
+	db.mem.restore(state)
+	return nil
+}
+
+// replayLog applies every complete record of the log. A trailing record
+// without its newline is the leftover of a crash in the middle of a write,
+// it was never acknowledged so it is cut off.
+func (db *FileDb) replayLog() error {
+	f, err := os.OpenFile(filepath.Join(db.dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+
+	var valid int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return err
+		}
+
+		var rec logRecord
+		if err := json.Unmarshal(bytes.TrimSpace(line), &rec); err != nil {
+			f.Close()
+			return fmt.Errorf("%w: corrupted log at offset %d: %v", ErrInternal, valid, err)
+		}
+		db.apply(rec)
+		valid += int64(len(line))
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+
+	db.log = f
+	return nil
+}
+
+func (db *FileDb) apply(rec logRecord) {
+	switch rec.Op {
+	case opPut:
+		if rec.Thread != nil && rec.Revision != nil {
+			db.mem.put(*rec.Thread, *rec.Revision)
+		} else if rec.Thread != nil && rec.Reaction != nil {
+			db.mem.putReaction(*rec.Thread, *rec.Reaction)
+		} else if rec.Thread != nil {
+			db.mem.put(*rec.Thread)
+		}
+		if rec.Reply != nil {
+			db.mem.putReply(*rec.Reply)
+		}
+		if rec.Webhook != nil {
+			db.mem.putWebhook(*rec.Webhook)
+		}
+		if rec.Delivery != nil {
+			db.mem.putDelivery(*rec.Delivery)
+		}
+	case opDelete:
+		db.mem.remove(rec.ID)
+	case opDeleteReply:
+		if rec.Thread != nil {
+			db.mem.removeReply(*rec.Thread, rec.ID)
+		}
+	case opDeleteReaction:
+		if rec.Thread != nil && rec.Reaction != nil {
+			db.mem.removeReaction(*rec.Thread, *rec.Reaction)
+		}
+	case opDeleteWebhook:
+		db.mem.removeWebhook(rec.ID)
+	case opAckOutbox:
+		db.mem.removeOutbox(rec.Acked...)
+	}
+
+	// an entry acknowledged after a snapshot whose log was not truncated
+	// comes back here, it is relayed again under the same key
+	if rec.Outbox != nil {
+		db.mem.putOutbox(*rec.Outbox)
+	}
+}
+
+func (db *FileDb) append(rec logRecord) error {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	if _, err := db.log.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if err := db.log.Sync(); err != nil {
+		return err
+	}
+
+	// the record is durable at this point, a failed compaction is retried
+	// on the next write instead of failing this one
+	db.writes++
+	if db.writes >= db.compactEvery {
+		if err := db.compact(); err != nil {
+			log.Printf("compacting %s: %v", db.dir, err)
+		}
+	}
+	return nil
+}
+
+// Compact writes the current state to the snapshot and empties the log.
+func (db *FileDb) Compact() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	return db.compact()
+}
+
+func (db *FileDb) compact() error {
+	data, err := json.Marshal(db.mem.state())
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(db.dir, snapshotFileName), data); err != nil {
+		return err
+	}
+
+	// records are full thread states, so a crash before the truncate only
+	// replays changes the snapshot already contains
+	if err := db.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := db.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	db.writes = 0
+	return db.log.Sync()
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// Close compacts the log and releases the files.
+func (db *FileDb) Close() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := db.compact(); err != nil {
+		db.log.Close()
+		return err
+	}
+	return db.log.Close()
+}
+
+// begin checks ctx before a write and returns the context the write hands
+// to mem. A write that started is carried out whole, memory and the log
+// would disagree otherwise.
+func begin(ctx context.Context) (context.Context, error) {
+	if err := checkContext(ctx); err != nil {
+		return ctx, err
+	}
+	return context.WithoutCancel(ctx), nil
+}
+
+func (db *FileDb) GetThreadByID(ctx context.Context, id string) (Thread, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetThreadByID(ctx, id)
+}
+
+func (db *FileDb) GetThreads(ctx context.Context) []Thread {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetThreads(ctx)
+}
+
+func (db *FileDb) ListThreads(ctx context.Context, opts ListOptions) (ThreadPage, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.ListThreads(ctx, opts)
+}
+
+func (db *FileDb) SearchThreads(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.SearchThreads(ctx, query, limit)
+}
+
+func (db *FileDb) GetRevisions(ctx context.Context, id string) ([]Revision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetRevisions(ctx, id)
+}
+
+func (db *FileDb) GetRevision(ctx context.Context, id string, number int) (Revision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetRevision(ctx, id, number)
+}
+
+func (db *FileDb) AddThread(ctx context.Context, author string, content string, tags ...string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	thread, err := db.mem.AddThread(ctx, author, content, tags...)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	revision, _ := db.mem.GetRevision(ctx, thread.ID, thread.Version)
+	entry := db.recorded()
+	if err := db.append(logRecord{Op: opPut, Thread: &thread, Revision: &revision, Outbox: entry}); err != nil {
+		db.mem.remove(thread.ID)
+		db.mem.removeOutbox(entry.ID)
+		return Thread{}, err
+	}
+	return thread, nil
+}
+
+func (db *FileDb) EditThread(ctx context.Context, id string, edit ThreadEdit) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	old, err := db.mem.GetThreadByID(ctx, id)
+	if err != nil {
+		return Thread{}, err
+	}
+	thread, err := db.mem.EditThread(ctx, id, edit)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	revision, _ := db.mem.GetRevision(ctx, id, thread.Version)
+	entry := db.recorded()
+	if err := db.append(logRecord{Op: opPut, Thread: &thread, Revision: &revision, Outbox: entry}); err != nil {
+		db.mem.rollback(old)
+		db.mem.removeOutbox(entry.ID)
+		return Thread{}, err
+	}
+	return thread, nil
+}
+
+func (db *FileDb) DeleteThread(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	old, err := db.mem.GetThreadByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := db.mem.DeleteThread(ctx, id); err != nil {
+		return err
+	}
+
+	// the thread goes to the trash with its revisions, a put is enough
+	thread, _ := db.mem.trashed(id)
+	entry := db.recorded()
+	if err := db.append(logRecord{Op: opPut, Thread: &thread, Outbox: entry}); err != nil {
+		db.mem.put(old)
+		db.mem.removeOutbox(entry.ID)
+		return err
+	}
+	return nil
+}
+
+func (db *FileDb) GetReplies(ctx context.Context, threadID string) ([]Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetReplies(ctx, threadID)
+}
+
+func (db *FileDb) GetReply(ctx context.Context, threadID string, replyID string) (Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetReply(ctx, threadID, replyID)
+}
+
+func (db *FileDb) GetReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetReplyTree(ctx, threadID, rootID, depth)
+}
+
+func (db *FileDb) AddReply(ctx context.Context, threadID string, parentID string, author string, content string) (Reply, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	old, err := db.mem.GetThreadByID(ctx, threadID)
+	if err != nil {
+		return Reply{}, err
+	}
+	reply, err := db.mem.AddReply(ctx, threadID, parentID, author, content)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	thread, _ := db.mem.GetThreadByID(ctx, threadID)
+	entry := db.recorded()
+	if err := db.append(logRecord{Op: opPut, Thread: &thread, Reply: &reply, Outbox: entry}); err != nil {
+		db.mem.removeReply(old, reply.ID)
+		db.mem.removeOutbox(entry.ID)
+		return Reply{}, err
+	}
+	return reply, nil
+}
+
+func (db *FileDb) EditReply(ctx context.Context, threadID string, replyID string, content string) (Reply, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	old, err := db.mem.GetReply(ctx, threadID, replyID)
+	if err != nil {
+		return Reply{}, err
+	}
+	reply, err := db.mem.EditReply(ctx, threadID, replyID, content)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	if err := db.append(logRecord{Op: opPut, Reply: &reply}); err != nil {
+		db.mem.putReply(old)
+		return Reply{}, err
+	}
+	return reply, nil
+}
+
+func (db *FileDb) DeleteReply(ctx context.Context, threadID string, replyID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	old, err := db.mem.GetThreadByID(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	replies, err := db.mem.GetReplyTree(ctx, threadID, replyID, 0)
+	if err != nil {
+		return err
+	}
+	if err := db.mem.DeleteReply(ctx, threadID, replyID); err != nil {
+		return err
+	}
+
+	// the replay removes the replies below it as well
+	thread, _ := db.mem.GetThreadByID(ctx, threadID)
+	if err := db.append(logRecord{Op: opDeleteReply, Thread: &thread, ID: replyID}); err != nil {
+		db.mem.put(old)
+		for _, reply := range replies {
+			db.mem.putReply(reply)
+		}
+		return err
+	}
+	return nil
+}
+
+func (db *FileDb) AddReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	return db.react(ctx, opPut, threadID, username, kind)
+}
+
+func (db *FileDb) RemoveReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	return db.react(ctx, opDeleteReaction, threadID, username, kind)
+}
+
+func (db *FileDb) react(ctx context.Context, op string, threadID string, username string, kind string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	old, err := db.mem.GetThreadByID(ctx, threadID)
+	if err != nil {
+		return Thread{}, err
+	}
+	reactions := db.mem.reactionsOf(threadID)
+
+	var thread Thread
+	if op == opPut {
+		thread, err = db.mem.AddReaction(ctx, threadID, username, kind)
+	} else {
+		thread, err = db.mem.RemoveReaction(ctx, threadID, username, kind)
+	}
+	if err != nil {
+		return Thread{}, err
+	}
+
+	reaction := Reaction{ThreadID: threadID, Username: username, Kind: kind}
+	for _, r := range db.mem.reactionsOf(threadID) {
+		if r.Username == username && r.Kind == kind {
+			reaction = r
+		}
+	}
+	if err := db.append(logRecord{Op: op, Thread: &thread, Reaction: &reaction}); err != nil {
+		db.mem.resetReactions(old, reactions)
+		return Thread{}, err
+	}
+	return thread, nil
+}
+
+func (db *FileDb) GetTags(ctx context.Context) ([]TagCount, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetTags(ctx)
+}
+
+func (db *FileDb) GetTrash(ctx context.Context) ([]Thread, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetTrash(ctx)
+}
+
+func (db *FileDb) RestoreThread(ctx context.Context, id string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Thread{}, err
+	}
+
+	old, ok := db.mem.trashed(id)
+	if !ok {
+		return Thread{}, ErrThreadNotFound
+	}
+
+	thread := old
+	thread.DeletedAt = nil
+	db.mem.put(thread)
+
+	if err := db.append(logRecord{Op: opPut, Thread: &thread}); err != nil {
+		db.mem.put(old)
+		return Thread{}, err
+	}
+	return thread, nil
+}
+
+// PurgeThreads logs the removal of each expired thread before forgetting
+// it, a failed write leaves that thread and the following ones in the trash.
+func (db *FileDb) PurgeThreads(ctx context.Context, before time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	trash, _ := db.mem.GetTrash(ctx)
+	for _, thread := range trash {
+		if !thread.DeletedAt.Before(before) {
+			continue
+		}
+		if err := db.append(logRecord{Op: opDelete, ID: thread.ID}); err != nil {
+			return purged, err
+		}
+		db.mem.remove(thread.ID)
+		purged++
+	}
+	return purged, nil
+}
+
+func (db *FileDb) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetWebhooks(ctx)
+}
+
+func (db *FileDb) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetWebhook(ctx, id)
+}
+
+func (db *FileDb) AddWebhook(ctx context.Context, url string, events []string, secret string) (Webhook, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	webhook, err := db.mem.AddWebhook(ctx, url, events, secret)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	if err := db.append(logRecord{Op: opPut, Webhook: &webhook}); err != nil {
+		db.mem.removeWebhook(webhook.ID)
+		return Webhook{}, err
+	}
+	return webhook, nil
+}
+
+func (db *FileDb) DeleteWebhook(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	old, err := db.mem.GetWebhook(ctx, id)
+	if err != nil {
+		return err
+	}
+	deliveries, _ := db.mem.GetDeliveries(ctx, id, "")
+	if err := db.mem.DeleteWebhook(ctx, id); err != nil {
+		return err
+	}
+
+	if err := db.append(logRecord{Op: opDeleteWebhook, ID: id}); err != nil {
+		db.mem.putWebhook(old)
+		for _, delivery := range deliveries {
+			db.mem.putDelivery(delivery)
+		}
+		return err
+	}
+	return nil
+}
+
+func (db *FileDb) GetDeliveries(ctx context.Context, webhookID string, status string) ([]Delivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetDeliveries(ctx, webhookID, status)
+}
+
+func (db *FileDb) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.DueDeliveries(ctx, now, limit)
+}
+
+func (db *FileDb) AddDelivery(ctx context.Context, webhookID string, event string, payload []byte) (Delivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	delivery, err := db.mem.AddDelivery(ctx, webhookID, event, payload)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err := db.append(logRecord{Op: opPut, Delivery: &delivery}); err != nil {
+		db.mem.removeDelivery(delivery.ID)
+		return Delivery{}, err
+	}
+	return delivery, nil
+}
+
+func (db *FileDb) UpdateDelivery(ctx context.Context, delivery Delivery) (Delivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	old, ok := db.mem.delivery(delivery.ID)
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	updated, err := db.mem.UpdateDelivery(ctx, delivery)
+	if err != nil {
+		return Delivery{}, err
+	}
+
+	if err := db.append(logRecord{Op: opPut, Delivery: &updated}); err != nil {
+		db.mem.putDelivery(old)
+		return Delivery{}, err
+	}
+	return updated, nil
+}
+
+// recorded returns the outbox entry of the write just made in memory, it
+// goes in the same log record as the write.
+func (db *FileDb) recorded() *OutboxEntry {
+	entry, _ := db.mem.lastOutbox()
+	return &entry
+}
+
+func (db *FileDb) GetOutbox(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	return db.mem.GetOutbox(ctx, limit)
+}
+
+func (db *FileDb) AckOutbox(ctx context.Context, ids ...int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	ctx, err := begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := db.append(logRecord{Op: opAckOutbox, Acked: ids}); err != nil {
+		return err
+	}
+	db.mem.removeOutbox(ids...)
+	return nil
+}
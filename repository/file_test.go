@@ -0,0 +1,258 @@
+package repository_test
+
+import (
+	"context"
+	"gofiber-api/repository"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+)
+
+type FileDbTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func TestFileDbTestSuite(t *testing.T) {
+	suite.Run(t, new(FileDbTestSuite))
+}
+
+func (s *FileDbTestSuite) SetupTest() {
+	s.dir = s.T().TempDir()
+}
+
+func (s *FileDbTestSuite) open(compactEvery int) *repository.FileDb {
+	db, err := repository.OpenFileDb(s.dir, compactEvery)
+	s.Require().NoError(err)
+	return db
+}
+
+func (s *FileDbTestSuite) TestRestoreAfterRestart() {
+	db := s.open(0)
+	db.AddThread(context.Background(), "the-author-1", "the content 1")
+	db.AddThread(context.Background(), "the-author-2", "the content 2")
+	db.AddThread(context.Background(), "the-author-3", "the content 3")
+	_, err := db.EditThread(context.Background(), "1", repository.ThreadEdit{Content: "the edited content"})
+	s.NoError(err)
+	s.NoError(db.DeleteThread(context.Background(), "2"))
+
+	// reopen without closing, as after a crash
+	db = s.open(0)
+	threads := db.GetThreads(context.Background())
+	s.Equal(2, len(threads))
+
+	thread, err := db.GetThreadByID(context.Background(), "1")
+	s.NoError(err)
+	s.Equal("the edited content", thread.Content)
+	s.True(thread.IsEdited)
+	s.Equal(2, thread.Version)
+
+	_, err = db.GetThreadByID(context.Background(), "2")
+	s.Error(err)
+
+	trash, err := db.GetTrash(context.Background())
+	s.NoError(err)
+	s.Equal(1, len(trash))
+	s.Equal("2", trash[0].ID)
+
+	results, err := db.SearchThreads(context.Background(), "edited", 0)
+	s.NoError(err)
+	s.Equal(1, len(results))
+
+	// the counter must not hand out the deleted id again
+	added, err := db.AddThread(context.Background(), "the-author-4", "the content 4")
+	s.NoError(err)
+	id := added.ID
+	s.Equal("3", id)
+
+	// replies and their deletion are replayed as well
+	reply, err := db.AddReply(context.Background(), "1", "", "the-replier", "the reply")
+	s.NoError(err)
+	_, err = db.AddReply(context.Background(), "1", "", "the-replier", "the second reply")
+	s.NoError(err)
+	_, err = db.AddReply(context.Background(), "1", reply.ID, "the-replier", "the nested reply")
+	s.NoError(err)
+	_, err = db.EditReply(context.Background(), "1", reply.ID, "the edited reply")
+	s.NoError(err)
+	s.NoError(db.DeleteReply(context.Background(), "1", reply.ID))
+
+	db = s.open(0)
+	replies, err := db.GetReplies(context.Background(), "1")
+	s.NoError(err)
+	s.Equal(1, len(replies))
+	s.Equal("the second reply", replies[0].Content)
+	thread, err = db.GetThreadByID(context.Background(), "1")
+	s.NoError(err)
+	s.Equal(1, thread.ReplyCount)
+
+	_, err = db.AddReaction(context.Background(), "1", "user-1", repository.ReactionDownvote)
+	s.NoError(err)
+	_, err = db.AddReaction(context.Background(), "1", "user-1", repository.ReactionUpvote)
+	s.NoError(err)
+	_, err = db.AddReaction(context.Background(), "1", "user-2", repository.ReactionLike)
+	s.NoError(err)
+	_, err = db.RemoveReaction(context.Background(), "1", "user-2", repository.ReactionLike)
+	s.NoError(err)
+
+	db = s.open(0)
+	thread, err = db.GetThreadByID(context.Background(), "1")
+	s.NoError(err)
+	s.Equal(1, thread.Score)
+	s.Equal(map[string]int{repository.ReactionUpvote: 1}, thread.Reactions)
+	// the replayed reactions still behave as sets
+	thread, err = db.AddReaction(context.Background(), "1", "user-1", repository.ReactionUpvote)
+	s.NoError(err)
+	s.Equal(1, thread.Score)
+
+	_, err = db.EditThread(context.Background(), "1", repository.ThreadEdit{Tags: []string{"kept"}})
+	s.NoError(err)
+
+	db = s.open(0)
+	tags, err := db.GetTags(context.Background())
+	s.NoError(err)
+	s.Equal([]repository.TagCount{{Tag: "kept", Count: 1}}, tags)
+
+	// restores and purges are replayed as well
+	_, err = db.RestoreThread(context.Background(), "2")
+	s.NoError(err)
+	s.NoError(db.DeleteThread(context.Background(), "0"))
+	_, err = db.PurgeThreads(context.Background(), time.Now())
+	s.NoError(err)
+
+	db = s.open(0)
+	s.Equal(3, len(db.GetThreads(context.Background())))
+	_, err = db.GetThreadByID(context.Background(), "2")
+	s.NoError(err)
+	trash, err = db.GetTrash(context.Background())
+	s.NoError(err)
+	s.Empty(trash)
+
+	// the webhooks and their delivery queue survive as well
+	webhook, err := db.AddWebhook(context.Background(), "http://example.com/hook", []string{"created"}, "the-secret")
+	s.NoError(err)
+	removed, err := db.AddWebhook(context.Background(), "http://example.com/removed", []string{"created"}, "the-secret")
+	s.NoError(err)
+	delivery, err := db.AddDelivery(context.Background(), webhook.ID, "created", []byte(`{"thread_id":"1"}`))
+	s.NoError(err)
+	delivery.Attempts = 1
+	_, err = db.UpdateDelivery(context.Background(), delivery)
+	s.NoError(err)
+	_, err = db.AddDelivery(context.Background(), removed.ID, "created", []byte(`{"thread_id":"1"}`))
+	s.NoError(err)
+	s.NoError(db.DeleteWebhook(context.Background(), removed.ID))
+
+	db = s.open(0)
+	webhooks, err := db.GetWebhooks(context.Background())
+	s.NoError(err)
+	s.Equal(1, len(webhooks))
+	s.Equal("the-secret", webhooks[0].Secret)
+	due, err := db.DueDeliveries(context.Background(), time.Now(), 0)
+	s.NoError(err)
+	s.Equal(1, len(due))
+	s.Equal(1, due[0].Attempts)
+	s.JSONEq(`{"thread_id":"1"}`, string(due[0].Payload))
+	// so does the outbox, with its acknowledgements
+	entries, err := db.GetOutbox(context.Background(), 0)
+	s.NoError(err)
+	s.NotEmpty(entries)
+	s.NoError(db.AckOutbox(context.Background(), entries[0].ID))
+
+	db = s.open(0)
+	left, err := db.GetOutbox(context.Background(), 0)
+	s.NoError(err)
+	s.Equal(len(entries)-1, len(left))
+	s.Equal(entries[1].Key, left[0].Key)
+	added, err = db.AddThread(context.Background(), "the-author-5", "the content 5")
+	s.NoError(err)
+	id = added.ID
+	latest, err := db.GetOutbox(context.Background(), 0)
+	s.NoError(err)
+	s.Equal(id, latest[len(latest)-1].ThreadID)
+	s.Greater(latest[len(latest)-1].ID, left[len(left)-1].ID)
+}
+
+func (s *FileDbTestSuite) TestTornWriteIsDiscarded() {
+	db := s.open(0)
+	db.AddThread(context.Background(), "the-author", "the content")
+
+	f, err := os.OpenFile(filepath.Join(s.dir, "threads.log"), os.O_APPEND|os.O_WRONLY, 0o644)
+	s.Require().NoError(err)
+	_, err = f.WriteString(`{"op":"put","thread":{"id":"1","auth`)
+	s.Require().NoError(err)
+	f.Close()
+
+	db = s.open(0)
+	s.Equal(1, len(db.GetThreads(context.Background())))
+
+	added, err := db.AddThread(context.Background(), "the-author-2", "the content 2")
+	s.NoError(err)
+	s.Equal("1", added.ID)
+
+	db = s.open(0)
+	s.Equal(2, len(db.GetThreads(context.Background())))
+}
+
+func (s *FileDbTestSuite) TestCompaction() {
+	db := s.open(2)
+	db.AddThread(context.Background(), "the-author-1", "the content 1")
+	db.AddThread(context.Background(), "the-author-2", "the content 2")
+
+	info, err := os.Stat(filepath.Join(s.dir, "threads.log"))
+	s.NoError(err)
+	s.Zero(info.Size())
+
+	db.AddThread(context.Background(), "the-author-3", "the content 3")
+	s.NoError(db.DeleteThread(context.Background(), "2"))
+	s.NoError(db.Close())
+
+	db = s.open(2)
+	threads := db.GetThreads(context.Background())
+	s.Equal(2, len(threads))
+	s.Equal("the-author-1", threads[0].Author)
+	s.Equal("the-author-2", threads[1].Author)
+
+	// revisions survive both the snapshot and the log replayed over it
+	_, err = db.EditThread(context.Background(), "0", repository.ThreadEdit{Content: "the edited content", Editor: "the-author-1"})
+	s.NoError(err)
+	db = s.open(2)
+	revisions, err := db.GetRevisions(context.Background(), "0")
+	s.NoError(err)
+	s.Equal(2, len(revisions))
+	s.Equal("the content 1", revisions[0].Content)
+	s.Equal("the edited content", revisions[1].Content)
+
+	added, err := db.AddThread(context.Background(), "the-author-4", "the content 4")
+	s.NoError(err)
+	s.Equal("3", added.ID)
+}
+
+func (s *FileDbTestSuite) TestReadsNeverSeeFailedWrites() {
+	db := s.open(0)
+	release, err := db.StallLog()
+	s.Require().NoError(err)
+
+	ctx := context.Background()
+	failed := make(chan error, 1)
+	go func() {
+		_, err := db.AddThread(ctx, "the-author", "the content")
+		failed <- err
+	}()
+	// let the write reach the log it is stuck on
+	time.Sleep(20 * time.Millisecond)
+
+	read := make(chan int, 1)
+	go func() {
+		read <- len(db.GetThreads(ctx))
+	}()
+	select {
+	case n := <-read:
+		s.Zero(n, "the write was read before it was in the log")
+	case <-time.After(20 * time.Millisecond):
+		release()
+		s.Error(<-failed)
+		s.Zero(<-read, "the write was read after it was rolled back")
+	}
+}
@@ -0,0 +1,236 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+const (
+	SortLastUpdate = "last_update"
+	SortCreated    = "created"
+	SortAuthor     = "author"
+	// SortTop ranks threads by Score
+	SortTop = "top"
+
+	OrderDesc = "desc"
+	OrderAsc  = "asc"
+)
+
+var (
+	ErrInvalidCursor = fmt.Errorf("%w: invalid cursor", ErrValidation)
+	ErrInvalidSort   = fmt.Errorf("%w: invalid sort", ErrValidation)
+)
+
+// ListOptions selects one page of the threads matching the filters. Threads
+// are ordered by Sort then ID, in the Order direction, the zero value lists
+// the most recently updated first. Cursor is the NextCursor of the previous
+// page and is only valid with the same filters and ordering.
+type ListOptions struct {
+	Limit  int
+	Cursor string
+
+	Author        string
+	Tag           string
+	Edited        *bool
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+
+	Sort  string
+	Order string
+}
+
+type ThreadPage struct {
+	Threads    []Thread `json:"threads"`
+	NextCursor string   `json:"next_cursor"`
+}
+
+// cursor points at the last thread of a page, the next page starts right
+// after it.
+type cursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Key   string `json:"k"`
+	ID    string `json:"id"`
+}
+
+func (opts ListOptions) limit() int {
+	return pageSize(opts.Limit)
+}
+
+// pageSize clamps a requested number of results to the allowed range.
+func pageSize(limit int) int {
+	if limit <= 0 {
+		return DefaultPageSize
+	}
+	if limit > MaxPageSize {
+		return MaxPageSize
+	}
+	return limit
+}
+
+// normalize fills the default ordering and rejects unknown ones.
+func (opts ListOptions) normalize() (ListOptions, error) {
+	if opts.Sort == "" {
+		opts.Sort = SortLastUpdate
+	}
+	if opts.Order == "" {
+		opts.Order = OrderDesc
+	}
+	opts.Tag = strings.ToLower(strings.TrimSpace(opts.Tag))
+
+	switch opts.Sort {
+	case SortLastUpdate, SortCreated, SortAuthor, SortTop:
+	default:
+		return opts, ErrInvalidSort
+	}
+	switch opts.Order {
+	case OrderDesc, OrderAsc:
+	default:
+		return opts, ErrInvalidSort
+	}
+	return opts, nil
+}
+
+func (opts ListOptions) matches(t Thread) bool {
+	if opts.Author != "" && t.Author != opts.Author {
+		return false
+	}
+	if opts.Tag != "" && !hasTag(t, opts.Tag) {
+		return false
+	}
+	if opts.Edited != nil && t.IsEdited != *opts.Edited {
+		return false
+	}
+	if !opts.CreatedAfter.IsZero() && !t.Created.After(opts.CreatedAfter) {
+		return false
+	}
+	if !opts.CreatedBefore.IsZero() && !t.Created.Before(opts.CreatedBefore) {
+		return false
+	}
+	return true
+}
+
+// sortKey is the value of the sort field of t as stored in cursors.
+func (opts ListOptions) sortKey(t Thread) string {
+	switch opts.Sort {
+	case SortCreated:
+		return strconv.FormatInt(t.Created.UnixNano(), 10)
+	case SortAuthor:
+		return t.Author
+	case SortTop:
+		return strconv.Itoa(t.Score)
+	default:
+		return strconv.FormatInt(t.LastUpdate.UnixNano(), 10)
+	}
+}
+
+func (opts ListOptions) compareKey(t Thread, key string) int {
+	switch opts.Sort {
+	case SortAuthor:
+		return strings.Compare(t.Author, key)
+	case SortCreated:
+		n, _ := strconv.ParseInt(key, 10, 64)
+		return compareInt(t.Created.UnixNano(), n)
+	case SortTop:
+		n, _ := strconv.ParseInt(key, 10, 64)
+		return compareInt(int64(t.Score), n)
+	default:
+		n, _ := strconv.ParseInt(key, 10, 64)
+		return compareInt(t.LastUpdate.UnixNano(), n)
+	}
+}
+
+func hasTag(t Thread, tag string) bool {
+	for _, tg := range t.Tags {
+		if tg == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func compareInt(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// comesAfter reports whether t is listed after the position (key, id).
+func (opts ListOptions) comesAfter(t Thread, key string, id string) bool {
+	c := opts.compareKey(t, key)
+	if c == 0 {
+		c = strings.Compare(t.ID, id)
+	}
+	if opts.Order == OrderDesc {
+		return c < 0
+	}
+	return c > 0
+}
+
+// less reports whether a is listed before b.
+func (opts ListOptions) less(a, b Thread) bool {
+	return opts.comesAfter(b, opts.sortKey(a), a.ID)
+}
+
+func (opts ListOptions) encodeCursor(t Thread) string {
+	data, _ := json.Marshal(cursor{
+		Sort:  opts.Sort,
+		Order: opts.Order,
+		Key:   opts.sortKey(t),
+		ID:    t.ID,
+	})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func (opts ListOptions) decodeCursor() (*cursor, error) {
+	if opts.Cursor == "" {
+		return nil, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(opts.Cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c cursor
+	if err := json.Unmarshal(data, &c); err != nil || c.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if c.Sort != opts.Sort || c.Order != opts.Order {
+		return nil, ErrInvalidCursor
+	}
+	if opts.Sort != SortAuthor {
+		if _, err := strconv.ParseInt(c.Key, 10, 64); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &c, nil
+}
+
+// newPage cuts threads, already in listing order, to the page size and
+// points the next cursor at the last thread kept when there are more.
+func (opts ListOptions) newPage(threads []Thread) ThreadPage {
+	limit := opts.limit()
+	if len(threads) <= limit {
+		return ThreadPage{Threads: threads}
+	}
+
+	threads = threads[:limit]
+	return ThreadPage{
+		Threads:    threads,
+		NextCursor: opts.encodeCursor(threads[limit-1]),
+	}
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// OutboxEntry is an event recorded by the write it describes, it stays in
+// the outbox until a relay acknowledges it. Key is unique to the event and
+// lets consumers drop the copies an interrupted relay sends again.
+type OutboxEntry struct {
+	ID       int64           `json:"id"`
+	Key      string          `json:"key"`
+	Type     string          `json:"type"`
+	ThreadID string          `json:"thread_id"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Created  time.Time       `json:"created"`
+}
+
+func newOutboxEntry(eventType string, threadID string, data interface{}) (OutboxEntry, error) {
+	key := make([]byte, 16)
+	if _, err := rand.Read(key); err != nil {
+		return OutboxEntry{}, internalError(err)
+	}
+
+	entry := OutboxEntry{
+		Key:      hex.EncodeToString(key),
+		Type:     eventType,
+		ThreadID: threadID,
+		Created:  time.Now(),
+	}
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return OutboxEntry{}, internalError(err)
+		}
+		entry.Data = raw
+	}
+	return entry, nil
+}
+
+// GetOutbox returns up to limit entries that were not acknowledged, in the
+// order they were recorded.
+func (db *Db) GetOutbox(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	entries := make([]OutboxEntry, 0, len(db.outbox))
+	for _, entry := range db.outbox {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+	if len(entries) > pageSize(limit) {
+		entries = entries[:pageSize(limit)]
+	}
+	return entries, nil
+}
+
+// AckOutbox removes the entries once they were relayed, unknown ids are
+// ignored so an acknowledgement can be repeated.
+func (db *Db) AckOutbox(ctx context.Context, ids ...int64) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	db.unsetOutbox(ids...)
+	return nil
+}
+
+// record adds an entry for the change being made, it must be called with
+// db.mu held by the write making the change.
+func (db *Db) record(eventType string, threadID string, data interface{}) (OutboxEntry, error) {
+	entry, err := newOutboxEntry(eventType, threadID, data)
+	if err != nil {
+		return OutboxEntry{}, err
+	}
+	entry.ID = db.outboxIncrement
+	db.setOutbox(entry)
+	return entry, nil
+}
+
+func (db *Db) setOutbox(entry OutboxEntry) {
+	db.outbox[entry.ID] = entry
+	if entry.ID >= db.outboxIncrement {
+		db.outboxIncrement = entry.ID + 1
+	}
+}
+
+func (db *Db) unsetOutbox(ids ...int64) {
+	for _, id := range ids {
+		delete(db.outbox, id)
+	}
+}
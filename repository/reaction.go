@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// Reaction kinds a user can leave on a thread, a user holds at most one
+// reaction of each kind per thread.
+const (
+	ReactionLike     = "like"
+	ReactionUpvote   = "upvote"
+	ReactionDownvote = "downvote"
+	ReactionHeart    = "heart"
+	ReactionLaugh    = "laugh"
+	ReactionSad      = "sad"
+)
+
+type Reaction struct {
+	ThreadID string    `json:"thread_id"`
+	Username string    `json:"username"`
+	Kind     string    `json:"kind"`
+	Created  time.Time `json:"created"`
+}
+
+// opposite returns the kind that cannot be held along with kind, an upvote
+// replaces a downvote and the other way around.
+func opposite(kind string) string {
+	switch kind {
+	case ReactionUpvote:
+		return ReactionDownvote
+	case ReactionDownvote:
+		return ReactionUpvote
+	}
+	return ""
+}
+
+// score ranks threads for SortTop, likes count as upvotes.
+func score(counts map[string]int) int {
+	return counts[ReactionUpvote] + counts[ReactionLike] - counts[ReactionDownvote]
+}
+
+// countReactions stores the reaction counts of reactions on thread.
+func countReactions(thread *Thread, reactions []Reaction) {
+	counts := make(map[string]int)
+	for _, reaction := range reactions {
+		counts[reaction.Kind]++
+	}
+	thread.Reactions = counts
+	thread.Score = score(counts)
+}
+
+func (db *Db) findReaction(threadID string, username string, kind string) int {
+	for i, reaction := range db.reactions[threadID] {
+		if reaction.Username == username && reaction.Kind == kind {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddReaction records that username reacted to the thread with kind and
+// returns the thread with its new counts. Reacting twice with the same kind
+// changes nothing.
+func (db *Db) AddReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
+	thread, ok := db.threads[threadID]
+	if !ok {
+		return Thread{}, ErrThreadNotFound
+	}
+
+	db.unsetReaction(threadID, username, opposite(kind))
+	db.setReaction(Reaction{
+		ThreadID: threadID,
+		Username: username,
+		Kind:     kind,
+		Created:  time.Now(),
+	})
+	countReactions(&thread, db.reactions[threadID])
+	db.threads[threadID] = thread
+	return thread, nil
+}
+
+// RemoveReaction takes back a reaction of username, removing a reaction
+// that was never left changes nothing.
+func (db *Db) RemoveReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
+	thread, ok := db.threads[threadID]
+	if !ok {
+		return Thread{}, ErrThreadNotFound
+	}
+
+	db.unsetReaction(threadID, username, kind)
+	countReactions(&thread, db.reactions[threadID])
+	db.threads[threadID] = thread
+	return thread, nil
+}
+
+// setReaction stores reaction unless the user already holds one of its kind.
+func (db *Db) setReaction(reaction Reaction) {
+	if db.findReaction(reaction.ThreadID, reaction.Username, reaction.Kind) >= 0 {
+		return
+	}
+	db.reactions[reaction.ThreadID] = append(db.reactions[reaction.ThreadID], reaction)
+}
+
+func (db *Db) unsetReaction(threadID string, username string, kind string) {
+	i := db.findReaction(threadID, username, kind)
+	if i < 0 {
+		return
+	}
+	reactions := db.reactions[threadID]
+	db.reactions[threadID] = append(reactions[:i:i], reactions[i+1:]...)
+}
@@ -0,0 +1,293 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gofiber-api/events"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrReplyNotFound  = fmt.Errorf("reply %w", ErrNotFound)
+	ErrParentNotFound = fmt.Errorf("parent reply %w", ErrNotFound)
+)
+
+// Reply is an answer posted under a thread, or under another reply of the
+// same thread when ParentID is set. Replies follow their thread to the
+// trash and are purged with it.
+type Reply struct {
+	ID         string    `json:"id"`
+	ThreadID   string    `json:"thread_id"`
+	ParentID   string    `json:"parent_id,omitempty"`
+	Created    time.Time `json:"created"`
+	LastUpdate time.Time `json:"last_update"`
+	Author     string    `json:"author"`
+	Content    string    `json:"content"`
+	IsEdited   bool      `json:"is_edited"`
+	// Depth is 1 for a reply to the thread and grows by one per level
+	Depth int `json:"depth"`
+}
+
+// ReplyNode is a reply with the replies answering it.
+type ReplyNode struct {
+	Reply
+	Replies []ReplyNode `json:"replies"`
+}
+
+// BuildReplyTree nests replies under their parent. Replies whose parent is
+// not in the list are roots. replies must be in creation order, as the
+// stores return them.
+func BuildReplyTree(replies []Reply) []ReplyNode {
+	children := make(map[string][]Reply, len(replies))
+	known := make(map[string]bool, len(replies))
+	for _, reply := range replies {
+		known[reply.ID] = true
+	}
+
+	roots := []Reply{}
+	for _, reply := range replies {
+		if reply.ParentID != "" && known[reply.ParentID] {
+			children[reply.ParentID] = append(children[reply.ParentID], reply)
+		} else {
+			roots = append(roots, reply)
+		}
+	}
+
+	var nest func(replies []Reply) []ReplyNode
+	nest = func(replies []Reply) []ReplyNode {
+		nodes := make([]ReplyNode, 0, len(replies))
+		for _, reply := range replies {
+			nodes = append(nodes, ReplyNode{
+				Reply:   reply,
+				Replies: nest(children[reply.ID]),
+			})
+		}
+		return nodes
+	}
+	return nest(roots)
+}
+
+// subtree picks from replies, in creation order, the reply rootID and the
+// replies below it, down to depth levels with the root being the first.
+// An empty rootID picks the whole thread, a non positive depth has no limit.
+func subtree(replies []Reply, rootID string, depth int) []Reply {
+	levels := make(map[string]int)
+	picked := []Reply{}
+	for _, reply := range replies {
+		var level int
+		switch {
+		case rootID == "" && reply.ParentID == "":
+			level = 1
+		case reply.ID == rootID:
+			level = 1
+		default:
+			parent, ok := levels[reply.ParentID]
+			if !ok {
+				continue
+			}
+			level = parent + 1
+		}
+		if depth > 0 && level > depth {
+			continue
+		}
+		levels[reply.ID] = level
+		picked = append(picked, reply)
+	}
+	return picked
+}
+
+// GetReplies returns the replies of a thread, oldest first.
+func (db *Db) GetReplies(ctx context.Context, threadID string) ([]Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, ok := db.threads[threadID]; !ok {
+		return nil, ErrThreadNotFound
+	}
+	return append([]Reply{}, db.replies[threadID]...), nil
+}
+
+func (db *Db) GetReply(ctx context.Context, threadID string, replyID string) (Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Reply{}, err
+	}
+
+	if _, ok := db.threads[threadID]; !ok {
+		return Reply{}, ErrThreadNotFound
+	}
+	i := db.findReply(threadID, replyID)
+	if i < 0 {
+		return Reply{}, ErrReplyNotFound
+	}
+	return db.replies[threadID][i], nil
+}
+
+// GetReplyTree returns the reply rootID and its descendants, or every reply
+// of the thread when rootID is empty, down to depth levels. The replies are
+// flat and oldest first, see BuildReplyTree.
+func (db *Db) GetReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]Reply, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, ok := db.threads[threadID]; !ok {
+		return nil, ErrThreadNotFound
+	}
+	if rootID != "" && db.findReply(threadID, rootID) < 0 {
+		return nil, ErrReplyNotFound
+	}
+	return subtree(db.replies[threadID], rootID, depth), nil
+}
+
+func (db *Db) findReply(threadID string, replyID string) int {
+	for i, reply := range db.replies[threadID] {
+		if reply.ID == replyID {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddReply posts a reply to the thread, or to the reply parentID of the
+// same thread when it is not empty.
+func (db *Db) AddReply(ctx context.Context, threadID string, parentID string, author string, content string) (Reply, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Reply{}, err
+	}
+
+	thread, ok := db.threads[threadID]
+	if !ok {
+		return Reply{}, ErrThreadNotFound
+	}
+	depth := 1
+	if parentID != "" {
+		i := db.findReply(threadID, parentID)
+		if i < 0 {
+			return Reply{}, ErrParentNotFound
+		}
+		depth = db.replies[threadID][i].Depth + 1
+	}
+
+	reply := Reply{
+		ID:         strconv.Itoa(db.replyIncrement),
+		ThreadID:   threadID,
+		ParentID:   parentID,
+		Created:    time.Now(),
+		LastUpdate: time.Now(),
+		Author:     author,
+		Content:    content,
+		Depth:      depth,
+	}
+	if _, err := db.record(events.ReplyCreated, threadID, reply); err != nil {
+		return Reply{}, err
+	}
+	db.setReply(reply)
+
+	thread.ReplyCount++
+	db.threads[threadID] = thread
+	return reply, nil
+}
+
+func (db *Db) EditReply(ctx context.Context, threadID string, replyID string, content string) (Reply, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Reply{}, err
+	}
+
+	if _, ok := db.threads[threadID]; !ok {
+		return Reply{}, ErrThreadNotFound
+	}
+	i := db.findReply(threadID, replyID)
+	if i < 0 {
+		return Reply{}, ErrReplyNotFound
+	}
+
+	reply := db.replies[threadID][i]
+	reply.Content = content
+	reply.LastUpdate = time.Now()
+	reply.IsEdited = true
+	db.replies[threadID][i] = reply
+	return reply, nil
+}
+
+// DeleteReply removes the reply along with every reply below it.
+func (db *Db) DeleteReply(ctx context.Context, threadID string, replyID string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	thread, ok := db.threads[threadID]
+	if !ok {
+		return ErrThreadNotFound
+	}
+	if db.findReply(threadID, replyID) < 0 {
+		return ErrReplyNotFound
+	}
+
+	thread.ReplyCount -= db.unsetReply(threadID, replyID)
+	db.threads[threadID] = thread
+	return nil
+}
+
+// setReply stores reply as-is, replacing the reply with the same id and
+// keeping the replies of the thread in creation order.
+func (db *Db) setReply(reply Reply) {
+	// records written before nesting
+	if reply.Depth == 0 {
+		reply.Depth = 1
+	}
+
+	replies := db.replies[reply.ThreadID]
+	if i := db.findReply(reply.ThreadID, reply.ID); i >= 0 {
+		replies[i] = reply
+	} else {
+		i := sort.Search(len(replies), func(i int) bool {
+			return replies[i].Created.After(reply.Created)
+		})
+		replies = append(replies, Reply{})
+		copy(replies[i+1:], replies[i:])
+		replies[i] = reply
+		db.replies[reply.ThreadID] = replies
+	}
+	if n, err := strconv.Atoi(reply.ID); err == nil && n >= db.replyIncrement {
+		db.replyIncrement = n + 1
+	}
+}
+
+// unsetReply forgets the reply and the replies below it, and returns how
+// many were forgotten.
+func (db *Db) unsetReply(threadID string, replyID string) int {
+	removed := make(map[string]bool)
+	for _, reply := range subtree(db.replies[threadID], replyID, 0) {
+		removed[reply.ID] = true
+	}
+
+	replies := make([]Reply, 0, len(db.replies[threadID]))
+	for _, reply := range db.replies[threadID] {
+		if !removed[reply.ID] {
+			replies = append(replies, reply)
+		}
+	}
+	db.replies[threadID] = replies
+	return len(removed)
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+var ErrRevisionNotFound = fmt.Errorf("revision %w", ErrNotFound)
+
+// Revision is the content of a thread at a version, revision numbers match
+// Thread.Version.
+type Revision struct {
+	ThreadID string    `json:"thread_id"`
+	Number   int       `json:"number"`
+	Content  string    `json:"content"`
+	Created  time.Time `json:"created"`
+	Editor   string    `json:"editor"`
+}
+
+func newRevision(thread Thread, editor string) Revision {
+	return Revision{
+		ThreadID: thread.ID,
+		Number:   thread.Version,
+		Content:  thread.Content,
+		Created:  thread.LastUpdate,
+		Editor:   editor,
+	}
+}
+
+// GetRevisions returns the revisions of a thread, oldest first.
+func (db *Db) GetRevisions(ctx context.Context, id string) ([]Revision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, ok := db.threads[id]; !ok {
+		return nil, ErrThreadNotFound
+	}
+	return append([]Revision{}, db.revisions[id]...), nil
+}
+
+func (db *Db) GetRevision(ctx context.Context, id string, number int) (Revision, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Revision{}, err
+	}
+
+	if _, ok := db.threads[id]; !ok {
+		return Revision{}, ErrThreadNotFound
+	}
+	for _, revision := range db.revisions[id] {
+		if revision.Number == number {
+			return revision, nil
+		}
+	}
+	return Revision{}, ErrRevisionNotFound
+}
@@ -0,0 +1,271 @@
+package repository
+
+import (
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+const snippetLength = 160
+
+type SearchResult struct {
+	Thread  Thread  `json:"thread"`
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// searchTerm is a word of a query, a trailing '*' turns it into a prefix.
+type searchTerm struct {
+	word   string
+	prefix bool
+}
+
+func (t searchTerm) matches(word string) bool {
+	if t.prefix {
+		return strings.HasPrefix(word, t.word)
+	}
+	return word == t.word
+}
+
+type searchHit struct {
+	id    string
+	score float64
+}
+
+// searchIndex is an inverted index over thread contents. It is safe for
+// concurrent use.
+type searchIndex struct {
+	mu sync.RWMutex
+	// postings maps a word to the frequency of the word in each thread
+	postings map[string]map[string]int
+	// docs keeps the words of each thread so they can be unindexed
+	docs map[string][]string
+	// words is the sorted vocabulary for prefix lookups, nil when stale
+	words []string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		postings: make(map[string]map[string]int),
+		docs:     make(map[string][]string),
+	}
+}
+
+type wordSpan struct {
+	word       string
+	start, end int
+}
+
+// tokenize splits s into lower cased words of letters and digits along with
+// their byte offsets in s.
+func tokenize(s string) []wordSpan {
+	spans := []wordSpan{}
+	start := -1
+	for i, r := range s {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			spans = append(spans, wordSpan{strings.ToLower(s[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		spans = append(spans, wordSpan{strings.ToLower(s[start:]), start, len(s)})
+	}
+	return spans
+}
+
+func parseQuery(q string) []searchTerm {
+	terms := []searchTerm{}
+	for _, field := range strings.Fields(q) {
+		spans := tokenize(field)
+		for i, span := range spans {
+			terms = append(terms, searchTerm{
+				word:   span.word,
+				prefix: i == len(spans)-1 && strings.HasSuffix(field, "*"),
+			})
+		}
+	}
+	return terms
+}
+
+func (idx *searchIndex) add(id string, content string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+
+	spans := tokenize(content)
+	words := make([]string, 0, len(spans))
+	for _, span := range spans {
+		freq, ok := idx.postings[span.word]
+		if !ok {
+			freq = make(map[string]int)
+			idx.postings[span.word] = freq
+			idx.words = nil
+		}
+		freq[id]++
+		words = append(words, span.word)
+	}
+	idx.docs[id] = words
+}
+
+func (idx *searchIndex) delete(id string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.remove(id)
+}
+
+func (idx *searchIndex) remove(id string) {
+	for _, word := range idx.docs[id] {
+		freq := idx.postings[word]
+		delete(freq, id)
+		if len(freq) == 0 {
+			delete(idx.postings, word)
+			idx.words = nil
+		}
+	}
+	delete(idx.docs, id)
+}
+
+func (idx *searchIndex) clear() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.postings = make(map[string]map[string]int)
+	idx.docs = make(map[string][]string)
+	idx.words = nil
+}
+
+// expand returns the indexed words matching term.
+func (idx *searchIndex) expand(term searchTerm) []string {
+	if !term.prefix {
+		if _, ok := idx.postings[term.word]; ok {
+			return []string{term.word}
+		}
+		return nil
+	}
+
+	if idx.words == nil {
+		idx.words = make([]string, 0, len(idx.postings))
+		for word := range idx.postings {
+			idx.words = append(idx.words, word)
+		}
+		sort.Strings(idx.words)
+	}
+
+	matched := []string{}
+	for i := sort.SearchStrings(idx.words, term.word); i < len(idx.words) && term.matches(idx.words[i]); i++ {
+		matched = append(matched, idx.words[i])
+	}
+	return matched
+}
+
+// search returns the threads containing every term, best match first. A
+// thread scores the tf-idf of the terms normalized by its length.
+func (idx *searchIndex) search(terms []searchTerm) []searchHit {
+	// expand may rebuild the vocabulary, so a write lock is needed
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if len(terms) == 0 {
+		return []searchHit{}
+	}
+
+	total := float64(len(idx.docs))
+	scores := map[string]float64{}
+	for i, term := range terms {
+		termScores := map[string]float64{}
+		for _, word := range idx.expand(term) {
+			freq := idx.postings[word]
+			idf := math.Log(1 + total/float64(len(freq)))
+			for id, tf := range freq {
+				termScores[id] += float64(tf) * idf / math.Sqrt(float64(len(idx.docs[id])))
+			}
+		}
+
+		// every term must match, keep the intersection
+		if i == 0 {
+			scores = termScores
+			continue
+		}
+		for id := range scores {
+			if s, ok := termScores[id]; ok {
+				scores[id] += s
+			} else {
+				delete(scores, id)
+			}
+		}
+	}
+
+	hits := make([]searchHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, searchHit{id, score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		return hits[i].id < hits[j].id
+	})
+	return hits
+}
+
+// snippet returns an html escaped excerpt of content around the first match
+// with every matching word wrapped in <mark>.
+func snippet(content string, terms []searchTerm) string {
+	spans := tokenize(content)
+	matched := []wordSpan{}
+	for _, span := range spans {
+		for _, term := range terms {
+			if term.matches(span.word) {
+				matched = append(matched, span)
+				break
+			}
+		}
+	}
+
+	start, end := 0, len(content)
+	if len(content) > snippetLength {
+		if len(matched) > 0 {
+			start = matched[0].start - snippetLength/4
+		}
+		start = max(start, 0)
+		end = min(start+snippetLength, len(content))
+		// keep the window on rune boundaries
+		for start > 0 && !utf8.RuneStart(content[start]) {
+			start--
+		}
+		for end < len(content) && !utf8.RuneStart(content[end]) {
+			end++
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, span := range matched {
+		if span.start < start || span.end > end {
+			continue
+		}
+		b.WriteString(html.EscapeString(content[pos:span.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(content[span.start:span.end]))
+		b.WriteString("</mark>")
+		pos = span.end
+	}
+	b.WriteString(html.EscapeString(content[pos:end]))
+	if end < len(content) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,1366 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gofiber-api/events"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// migrations are applied in order, a migration is never edited once
+// released, add a new one instead.
+var migrations = []string{
+	`CREATE TABLE threads (
+		id          TEXT PRIMARY KEY,
+		created     INTEGER NOT NULL,
+		last_update INTEGER NOT NULL,
+		author      TEXT NOT NULL,
+		content     TEXT NOT NULL,
+		is_edited   INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE TABLE sequences (
+		name TEXT PRIMARY KEY,
+		next INTEGER NOT NULL
+	);
+	INSERT INTO sequences (name, next) VALUES ('threads', 0);`,
+
+	`CREATE INDEX threads_last_update ON threads (last_update);
+	CREATE INDEX threads_author ON threads (author);`,
+
+	`ALTER TABLE threads ADD COLUMN version INTEGER NOT NULL DEFAULT 1;`,
+
+	// threads created before revisions keep their current content as the
+	// only revision
+	`CREATE TABLE revisions (
+		thread_id TEXT NOT NULL,
+		number    INTEGER NOT NULL,
+		content   TEXT NOT NULL,
+		created   INTEGER NOT NULL,
+		editor    TEXT NOT NULL,
+		PRIMARY KEY (thread_id, number)
+	);
+	INSERT INTO revisions (thread_id, number, content, created, editor)
+		SELECT id, version, content, last_update, author FROM threads;`,
+
+	`ALTER TABLE threads ADD COLUMN deleted_at INTEGER;
+	CREATE INDEX threads_deleted_at ON threads (deleted_at);`,
+
+	`CREATE TABLE replies (
+		id          TEXT PRIMARY KEY,
+		thread_id   TEXT NOT NULL,
+		created     INTEGER NOT NULL,
+		last_update INTEGER NOT NULL,
+		author      TEXT NOT NULL,
+		content     TEXT NOT NULL,
+		is_edited   INTEGER NOT NULL DEFAULT 0
+	);
+	CREATE INDEX replies_thread ON replies (thread_id, created);
+	ALTER TABLE threads ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+	INSERT INTO sequences (name, next) VALUES ('replies', 0);`,
+
+	`ALTER TABLE replies ADD COLUMN parent_id TEXT;
+	ALTER TABLE replies ADD COLUMN depth INTEGER NOT NULL DEFAULT 1;
+	CREATE INDEX replies_parent ON replies (parent_id);`,
+
+	`CREATE TABLE reactions (
+		thread_id TEXT NOT NULL,
+		username  TEXT NOT NULL,
+		kind      TEXT NOT NULL,
+		created   INTEGER NOT NULL,
+		PRIMARY KEY (thread_id, username, kind)
+	);
+	ALTER TABLE threads ADD COLUMN reactions TEXT NOT NULL DEFAULT '{}';
+	ALTER TABLE threads ADD COLUMN score INTEGER NOT NULL DEFAULT 0;
+	CREATE INDEX threads_score ON threads (score);`,
+
+	// thread_tags is the tag index, threads.tags keeps them for reading
+	`ALTER TABLE threads ADD COLUMN tags TEXT NOT NULL DEFAULT '[]';
+	CREATE TABLE thread_tags (
+		tag       TEXT NOT NULL,
+		thread_id TEXT NOT NULL,
+		PRIMARY KEY (tag, thread_id)
+	);
+	CREATE INDEX thread_tags_thread ON thread_tags (thread_id);`,
+
+	`CREATE TABLE webhooks (
+		id      TEXT PRIMARY KEY,
+		url     TEXT NOT NULL,
+		events  TEXT NOT NULL,
+		secret  TEXT NOT NULL,
+		created INTEGER NOT NULL
+	);
+	CREATE TABLE webhook_deliveries (
+		id              TEXT PRIMARY KEY,
+		webhook_id      TEXT NOT NULL REFERENCES webhooks (id) ON DELETE CASCADE,
+		event           TEXT NOT NULL,
+		payload         TEXT NOT NULL,
+		status          TEXT NOT NULL,
+		attempts        INTEGER NOT NULL DEFAULT 0,
+		next_attempt    INTEGER NOT NULL,
+		response_status INTEGER NOT NULL DEFAULT 0,
+		last_error      TEXT NOT NULL DEFAULT '',
+		created         INTEGER NOT NULL,
+		last_update     INTEGER NOT NULL
+	);
+	CREATE INDEX webhook_deliveries_due ON webhook_deliveries (status, next_attempt);
+	CREATE INDEX webhook_deliveries_webhook ON webhook_deliveries (webhook_id, created);
+	INSERT INTO sequences (name, next) VALUES ('webhooks', 0), ('deliveries', 0);`,
+
+	`CREATE TABLE outbox (
+		id        INTEGER PRIMARY KEY AUTOINCREMENT,
+		key       TEXT NOT NULL UNIQUE,
+		type      TEXT NOT NULL,
+		thread_id TEXT NOT NULL,
+		data      TEXT,
+		created   INTEGER NOT NULL
+	);`,
+}
+
+// SqliteDb is a thread store backed by a SQLite database.
+type SqliteDb struct {
+	db *sql.DB
+	// index is rebuilt from the table on open and kept in sync by the writes
+	index *searchIndex
+	// indexMu orders the updates of the index like the commits they follow
+	indexMu sync.Mutex
+}
+
+// OpenSqliteDb opens the database at path and brings its schema up to date.
+func OpenSqliteDb(path string) (*SqliteDb, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// sqlite allows a single writer, one connection avoids "database is locked"
+	db.SetMaxOpenConns(1)
+
+	s := &SqliteDb{
+		db:    db,
+		index: newSearchIndex(),
+	}
+	if err := s.migrate(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := s.buildIndex(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *SqliteDb) buildIndex(ctx context.Context) error {
+	rows, err := s.db.QueryContext(ctx, `SELECT id, content FROM threads WHERE deleted_at IS NULL`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id, content string
+		if err := rows.Scan(&id, &content); err != nil {
+			return err
+		}
+		s.index.add(id, content)
+	}
+	return rows.Err()
+}
+
+func (s *SqliteDb) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return err
+	}
+
+	var current int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return err
+	}
+
+	for i := current; i < len(migrations); i++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// commitIndexed commits tx and applies update to the index before another
+// write can commit, so the index ends up matching the last commit.
+func (s *SqliteDb) commitIndexed(tx *sql.Tx, update func()) error {
+	s.indexMu.Lock()
+	defer s.indexMu.Unlock()
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	update()
+	return nil
+}
+
+func (s *SqliteDb) Close() error {
+	return s.db.Close()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanThread(row rowScanner) (Thread, error) {
+	var (
+		t                   Thread
+		created, lastUpdate int64
+		deletedAt           sql.NullInt64
+		reactions, tags     string
+	)
+	if err := row.Scan(&t.ID, &created, &lastUpdate, &t.Author, &t.Content, &t.IsEdited, &t.Version, &deletedAt, &t.ReplyCount, &reactions, &t.Score, &tags); err != nil {
+		return Thread{}, err
+	}
+	if err := json.Unmarshal([]byte(reactions), &t.Reactions); err != nil {
+		return Thread{}, err
+	}
+	if err := json.Unmarshal([]byte(tags), &t.Tags); err != nil {
+		return Thread{}, err
+	}
+	t.Created = time.Unix(0, created)
+	t.LastUpdate = time.Unix(0, lastUpdate)
+	if deletedAt.Valid {
+		deleted := time.Unix(0, deletedAt.Int64)
+		t.DeletedAt = &deleted
+	}
+	return t, nil
+}
+
+const threadColumns = `id, created, last_update, author, content, is_edited, version, deleted_at, reply_count, reactions, score, tags`
+
+func (s *SqliteDb) GetThreadByID(ctx context.Context, id string) (Thread, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE id = ? AND deleted_at IS NULL`, id)
+	thread, err := scanThread(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Thread{}, ErrThreadNotFound
+	}
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+func (s *SqliteDb) GetThreads(ctx context.Context) []Thread {
+	t := []Thread{}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE deleted_at IS NULL ORDER BY created, id`)
+	if err != nil {
+		log.Printf("listing threads: %v", err)
+		return t
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			log.Printf("listing threads: %v", err)
+			return t
+		}
+		t = append(t, thread)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("listing threads: %v", err)
+	}
+	return t
+}
+
+func (s *SqliteDb) ListThreads(ctx context.Context, opts ListOptions) (ThreadPage, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return ThreadPage{}, err
+	}
+	after, err := opts.decodeCursor()
+	if err != nil {
+		return ThreadPage{}, err
+	}
+
+	where := []string{`deleted_at IS NULL`}
+	args := []interface{}{}
+	if opts.Author != "" {
+		where = append(where, `author = ?`)
+		args = append(args, opts.Author)
+	}
+	if opts.Tag != "" {
+		where = append(where, `id IN (SELECT thread_id FROM thread_tags WHERE tag = ?)`)
+		args = append(args, opts.Tag)
+	}
+	if opts.Edited != nil {
+		where = append(where, `is_edited = ?`)
+		args = append(args, *opts.Edited)
+	}
+	if !opts.CreatedAfter.IsZero() {
+		where = append(where, `created > ?`)
+		args = append(args, opts.CreatedAfter.UnixNano())
+	}
+	if !opts.CreatedBefore.IsZero() {
+		where = append(where, `created < ?`)
+		args = append(args, opts.CreatedBefore.UnixNano())
+	}
+
+	// opts.Sort and opts.Order are validated by normalize
+	column := opts.Sort
+	if opts.Sort == SortTop {
+		column = `score`
+	}
+	direction, cmp := `DESC`, `<`
+	if opts.Order == OrderAsc {
+		direction, cmp = `ASC`, `>`
+	}
+	if after != nil {
+		var key interface{} = after.Key
+		if opts.Sort != SortAuthor {
+			key, _ = strconv.ParseInt(after.Key, 10, 64)
+		}
+		where = append(where, fmt.Sprintf(`(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))`, column, cmp))
+		args = append(args, key, key, after.ID)
+	}
+
+	query := `SELECT ` + threadColumns + ` FROM threads WHERE ` + strings.Join(where, ` AND `)
+	// one extra row tells whether there is a next page
+	query += fmt.Sprintf(` ORDER BY %[1]s %[2]s, id %[2]s LIMIT ?`, column, direction)
+	args = append(args, opts.limit()+1)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return ThreadPage{}, internalError(err)
+	}
+	defer rows.Close()
+
+	t := []Thread{}
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return ThreadPage{}, internalError(err)
+		}
+		t = append(t, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return ThreadPage{}, internalError(err)
+	}
+	return opts.newPage(t), nil
+}
+
+// nextID hands out the next id of the named sequence.
+func nextID(ctx context.Context, tx *sql.Tx, name string) (string, error) {
+	var next int
+	if err := tx.QueryRowContext(ctx, `SELECT next FROM sequences WHERE name = ?`, name).Scan(&next); err != nil {
+		return "", err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE sequences SET next = next + 1 WHERE name = ?`, name); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(next), nil
+}
+
+// setTags replaces the tags of a thread in both the column and the index.
+func setTags(ctx context.Context, tx *sql.Tx, id string, tags []string) error {
+	data, err := json.Marshal(tags)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE threads SET tags = ? WHERE id = ?`, string(data), id); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM thread_tags WHERE thread_id = ?`, id); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, `INSERT INTO thread_tags (tag, thread_id) VALUES (?, ?)`, tag, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SqliteDb) AddThread(ctx context.Context, author string, content string, tags ...string) (Thread, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	id, err := nextID(ctx, tx, "threads")
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+
+	now := time.Now().UnixNano()
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO threads (`+threadColumns+`) VALUES (?, ?, ?, ?, ?, 0, 1, NULL, 0, '{}', 0, '[]')`,
+		id, now, now, author, content,
+	); err != nil {
+		return Thread{}, internalError(err)
+	}
+	if err := setTags(ctx, tx, id, normalizeTags(tags)); err != nil {
+		return Thread{}, internalError(err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO revisions (`+revisionColumns+`) VALUES (?, 1, ?, ?, ?)`,
+		id, content, now, author,
+	); err != nil {
+		return Thread{}, internalError(err)
+	}
+	thread, err := getThread(ctx, tx, id)
+	if err != nil {
+		return Thread{}, err
+	}
+	if err := record(ctx, tx, events.ThreadCreated, id, thread); err != nil {
+		return Thread{}, err
+	}
+
+	if err := s.commitIndexed(tx, func() { s.index.add(id, content) }); err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+func (s *SqliteDb) EditThread(ctx context.Context, id string, edit ThreadEdit) (Thread, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	thread, err := scanThread(tx.QueryRowContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Thread{}, ErrThreadNotFound
+	}
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	if edit.Version != 0 && thread.Version != edit.Version {
+		return Thread{}, ErrVersionMismatch
+	}
+
+	if edit.Content != "" {
+		thread.Content = edit.Content
+	}
+	thread.LastUpdate = time.Unix(0, time.Now().UnixNano())
+	thread.IsEdited = true
+	thread.Version++
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE threads SET content = ?, last_update = ?, is_edited = 1, version = ? WHERE id = ?`,
+		thread.Content, thread.LastUpdate.UnixNano(), thread.Version, id,
+	); err != nil {
+		return Thread{}, internalError(err)
+	}
+	if edit.Tags != nil {
+		thread.Tags = normalizeTags(edit.Tags)
+		if err := setTags(ctx, tx, id, thread.Tags); err != nil {
+			return Thread{}, internalError(err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO revisions (`+revisionColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		id, thread.Version, thread.Content, thread.LastUpdate.UnixNano(), edit.Editor,
+	); err != nil {
+		return Thread{}, internalError(err)
+	}
+	if err := record(ctx, tx, events.ThreadEdited, id, thread); err != nil {
+		return Thread{}, err
+	}
+
+	if err := s.commitIndexed(tx, func() { s.index.add(id, thread.Content) }); err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+// DeleteThread moves the thread to the trash, it keeps its revisions until
+// it is purged.
+func (s *SqliteDb) DeleteThread(ctx context.Context, id string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return internalError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE threads SET deleted_at = ? WHERE id = ? AND deleted_at IS NULL`,
+		time.Now().UnixNano(), id,
+	)
+	if err != nil {
+		return internalError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return internalError(err)
+	} else if n == 0 {
+		return ErrThreadNotFound
+	}
+	if err := record(ctx, tx, events.ThreadDeleted, id, nil); err != nil {
+		return err
+	}
+
+	if err := s.commitIndexed(tx, func() { s.index.delete(id) }); err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+func (s *SqliteDb) GetTrash(ctx context.Context) ([]Thread, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE deleted_at IS NOT NULL ORDER BY deleted_at DESC, id DESC`)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	t := []Thread{}
+	for rows.Next() {
+		thread, err := scanThread(rows)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		t = append(t, thread)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return t, nil
+}
+
+func (s *SqliteDb) RestoreThread(ctx context.Context, id string) (Thread, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `UPDATE threads SET deleted_at = NULL WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return Thread{}, internalError(err)
+	} else if n == 0 {
+		return Thread{}, ErrThreadNotFound
+	}
+	thread, err := scanThread(tx.QueryRowContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE id = ?`, id))
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+
+	if err := s.commitIndexed(tx, func() { s.index.add(id, thread.Content) }); err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+func (s *SqliteDb) PurgeThreads(ctx context.Context, before time.Time) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, internalError(err)
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"revisions", "replies", "reactions", "thread_tags"} {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM `+table+` WHERE thread_id IN (SELECT id FROM threads WHERE deleted_at < ?)`,
+			before.UnixNano(),
+		); err != nil {
+			return 0, internalError(err)
+		}
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM threads WHERE deleted_at < ?`, before.UnixNano())
+	if err != nil {
+		return 0, internalError(err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, internalError(err)
+	}
+	return int(n), nil
+}
+
+const revisionColumns = `thread_id, number, content, created, editor`
+
+func scanRevision(row rowScanner) (Revision, error) {
+	var (
+		r       Revision
+		created int64
+	)
+	if err := row.Scan(&r.ThreadID, &r.Number, &r.Content, &created, &r.Editor); err != nil {
+		return Revision{}, err
+	}
+	r.Created = time.Unix(0, created)
+	return r, nil
+}
+
+func (s *SqliteDb) GetRevisions(ctx context.Context, id string) ([]Revision, error) {
+	if _, err := s.GetThreadByID(ctx, id); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT `+revisionColumns+` FROM revisions WHERE thread_id = ? ORDER BY number`, id)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		revision, err := scanRevision(rows)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		revisions = append(revisions, revision)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return revisions, nil
+}
+
+func (s *SqliteDb) GetRevision(ctx context.Context, id string, number int) (Revision, error) {
+	if _, err := s.GetThreadByID(ctx, id); err != nil {
+		return Revision{}, err
+	}
+
+	row := s.db.QueryRowContext(ctx, `SELECT `+revisionColumns+` FROM revisions WHERE thread_id = ? AND number = ?`, id, number)
+	revision, err := scanRevision(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Revision{}, ErrRevisionNotFound
+	}
+	if err != nil {
+		return Revision{}, internalError(err)
+	}
+	return revision, nil
+}
+
+func (s *SqliteDb) SearchThreads(ctx context.Context, query string, limit int) ([]SearchResult, error) {
+	terms := parseQuery(query)
+	hits := s.index.search(terms)
+
+	results := []SearchResult{}
+	for _, hit := range hits {
+		if len(results) == pageSize(limit) {
+			break
+		}
+		thread, err := s.GetThreadByID(ctx, hit.id)
+		if errors.Is(err, ErrNotFound) {
+			// deleted since the index was read
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, SearchResult{
+			Thread:  thread,
+			Score:   hit.score,
+			Snippet: snippet(thread.Content, terms),
+		})
+	}
+	return results, nil
+}
+
+const replyColumns = `id, thread_id, created, last_update, author, content, is_edited, parent_id, depth`
+
+func scanReply(row rowScanner) (Reply, error) {
+	var (
+		r                   Reply
+		created, lastUpdate int64
+		parentID            sql.NullString
+	)
+	if err := row.Scan(&r.ID, &r.ThreadID, &created, &lastUpdate, &r.Author, &r.Content, &r.IsEdited, &parentID, &r.Depth); err != nil {
+		return Reply{}, err
+	}
+	r.ParentID = parentID.String
+	r.Created = time.Unix(0, created)
+	r.LastUpdate = time.Unix(0, lastUpdate)
+	return r, nil
+}
+
+func (s *SqliteDb) GetReplies(ctx context.Context, threadID string) ([]Reply, error) {
+	if _, err := s.GetThreadByID(ctx, threadID); err != nil {
+		return nil, err
+	}
+
+	return queryReplies(ctx, s.db, `SELECT `+replyColumns+` FROM replies WHERE thread_id = ? ORDER BY created, id`, threadID)
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+func queryReplies(ctx context.Context, q queryer, query string, args ...interface{}) ([]Reply, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	replies := []Reply{}
+	for rows.Next() {
+		reply, err := scanReply(rows)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		replies = append(replies, reply)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return replies, nil
+}
+
+// replyTreeQuery walks down from the roots of the tree with a recursive
+// query, so a deep subtree is loaded without reading the whole thread. The
+// arguments are the thread id, the root id or NULL for the whole thread,
+// and the depth limit.
+const replyTreeQuery = `WITH RECURSIVE tree (id, level) AS (
+		SELECT id, 1 FROM replies
+		WHERE thread_id = ?1 AND (id = ?2 OR (?2 IS NULL AND parent_id IS NULL))
+		UNION ALL
+		SELECT replies.id, tree.level + 1 FROM replies
+		JOIN tree ON replies.parent_id = tree.id
+		WHERE ?3 <= 0 OR tree.level < ?3
+	)
+	SELECT ` + replyColumns + ` FROM replies WHERE id IN (SELECT id FROM tree)
+	ORDER BY created, id`
+
+func (s *SqliteDb) GetReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]Reply, error) {
+	if _, err := s.GetThreadByID(ctx, threadID); err != nil {
+		return nil, err
+	}
+	return replyTree(ctx, s.db, threadID, rootID, depth)
+}
+
+func replyTree(ctx context.Context, q queryer, threadID string, rootID string, depth int) ([]Reply, error) {
+	root := sql.NullString{String: rootID, Valid: rootID != ""}
+	replies, err := queryReplies(ctx, q, replyTreeQuery, threadID, root, depth)
+	if err != nil {
+		return nil, err
+	}
+	if rootID != "" && len(replies) == 0 {
+		return nil, ErrReplyNotFound
+	}
+	return replies, nil
+}
+
+func (s *SqliteDb) GetReply(ctx context.Context, threadID string, replyID string) (Reply, error) {
+	if _, err := s.GetThreadByID(ctx, threadID); err != nil {
+		return Reply{}, err
+	}
+	return getReply(ctx, s.db, threadID, replyID)
+}
+
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func getReply(ctx context.Context, q queryRower, threadID string, replyID string) (Reply, error) {
+	row := q.QueryRowContext(ctx, `SELECT `+replyColumns+` FROM replies WHERE id = ? AND thread_id = ?`, replyID, threadID)
+	reply, err := scanReply(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Reply{}, ErrReplyNotFound
+	}
+	if err != nil {
+		return Reply{}, internalError(err)
+	}
+	return reply, nil
+}
+
+// changeReplyCount adds delta to the reply count of a thread that is not in
+// the trash.
+func changeReplyCount(ctx context.Context, tx *sql.Tx, threadID string, delta int) error {
+	res, err := tx.ExecContext(ctx,
+		`UPDATE threads SET reply_count = reply_count + ? WHERE id = ? AND deleted_at IS NULL`,
+		delta, threadID,
+	)
+	if err != nil {
+		return internalError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return internalError(err)
+	} else if n == 0 {
+		return ErrThreadNotFound
+	}
+	return nil
+}
+
+func (s *SqliteDb) AddReply(ctx context.Context, threadID string, parentID string, author string, content string) (Reply, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Reply{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	if err := changeReplyCount(ctx, tx, threadID, 1); err != nil {
+		return Reply{}, err
+	}
+	depth := 1
+	if parentID != "" {
+		parent, err := getReply(ctx, tx, threadID, parentID)
+		if errors.Is(err, ErrReplyNotFound) {
+			return Reply{}, ErrParentNotFound
+		}
+		if err != nil {
+			return Reply{}, err
+		}
+		depth = parent.Depth + 1
+	}
+	id, err := nextID(ctx, tx, "replies")
+	if err != nil {
+		return Reply{}, internalError(err)
+	}
+
+	now := time.Unix(0, time.Now().UnixNano())
+	reply := Reply{
+		ID:         id,
+		ThreadID:   threadID,
+		ParentID:   parentID,
+		Created:    now,
+		LastUpdate: now,
+		Author:     author,
+		Content:    content,
+		Depth:      depth,
+	}
+	parent := sql.NullString{String: parentID, Valid: parentID != ""}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO replies (`+replyColumns+`) VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)`,
+		reply.ID, reply.ThreadID, now.UnixNano(), now.UnixNano(), reply.Author, reply.Content, parent, reply.Depth,
+	); err != nil {
+		return Reply{}, internalError(err)
+	}
+	if err := record(ctx, tx, events.ReplyCreated, threadID, reply); err != nil {
+		return Reply{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Reply{}, internalError(err)
+	}
+	return reply, nil
+}
+
+func (s *SqliteDb) EditReply(ctx context.Context, threadID string, replyID string, content string) (Reply, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Reply{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	// a zero delta only checks the thread is not in the trash
+	if err := changeReplyCount(ctx, tx, threadID, 0); err != nil {
+		return Reply{}, err
+	}
+	reply, err := getReply(ctx, tx, threadID, replyID)
+	if err != nil {
+		return Reply{}, err
+	}
+
+	reply.Content = content
+	reply.LastUpdate = time.Unix(0, time.Now().UnixNano())
+	reply.IsEdited = true
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE replies SET content = ?, last_update = ?, is_edited = 1 WHERE id = ?`,
+		reply.Content, reply.LastUpdate.UnixNano(), replyID,
+	); err != nil {
+		return Reply{}, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Reply{}, internalError(err)
+	}
+	return reply, nil
+}
+
+// DeleteReply removes the reply along with every reply below it.
+func (s *SqliteDb) DeleteReply(ctx context.Context, threadID string, replyID string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return internalError(err)
+	}
+	defer tx.Rollback()
+
+	// a zero delta only checks the thread is not in the trash
+	if err := changeReplyCount(ctx, tx, threadID, 0); err != nil {
+		return err
+	}
+	replies, err := replyTree(ctx, tx, threadID, replyID, 0)
+	if err != nil {
+		return err
+	}
+	if err := changeReplyCount(ctx, tx, threadID, -len(replies)); err != nil {
+		return err
+	}
+	for _, reply := range replies {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM replies WHERE id = ?`, reply.ID); err != nil {
+			return internalError(err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+// getThread reads a thread that is not in the trash within tx.
+func getThread(ctx context.Context, tx *sql.Tx, id string) (Thread, error) {
+	thread, err := scanThread(tx.QueryRowContext(ctx, `SELECT `+threadColumns+` FROM threads WHERE id = ? AND deleted_at IS NULL`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Thread{}, ErrThreadNotFound
+	}
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+func (s *SqliteDb) AddReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	return s.react(ctx, threadID, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx,
+			`DELETE FROM reactions WHERE thread_id = ? AND username = ? AND kind = ?`,
+			threadID, username, opposite(kind),
+		); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx,
+			`INSERT OR IGNORE INTO reactions (thread_id, username, kind, created) VALUES (?, ?, ?, ?)`,
+			threadID, username, kind, time.Now().UnixNano(),
+		)
+		return err
+	})
+}
+
+func (s *SqliteDb) RemoveReaction(ctx context.Context, threadID string, username string, kind string) (Thread, error) {
+	return s.react(ctx, threadID, func(tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx,
+			`DELETE FROM reactions WHERE thread_id = ? AND username = ? AND kind = ?`,
+			threadID, username, kind,
+		)
+		return err
+	})
+}
+
+// react applies change to the reactions of the thread and recounts them.
+func (s *SqliteDb) react(ctx context.Context, threadID string, change func(tx *sql.Tx) error) (Thread, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	thread, err := getThread(ctx, tx, threadID)
+	if err != nil {
+		return Thread{}, err
+	}
+	if err := change(tx); err != nil {
+		return Thread{}, internalError(err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT kind, COUNT(*) FROM reactions WHERE thread_id = ? GROUP BY kind`, threadID)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var (
+			kind  string
+			count int
+		)
+		if err := rows.Scan(&kind, &count); err != nil {
+			return Thread{}, internalError(err)
+		}
+		counts[kind] = count
+	}
+	if err := rows.Err(); err != nil {
+		return Thread{}, internalError(err)
+	}
+
+	thread.Reactions = counts
+	thread.Score = score(counts)
+	data, err := json.Marshal(counts)
+	if err != nil {
+		return Thread{}, internalError(err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE threads SET reactions = ?, score = ? WHERE id = ?`,
+		string(data), thread.Score, threadID,
+	); err != nil {
+		return Thread{}, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Thread{}, internalError(err)
+	}
+	return thread, nil
+}
+
+func (s *SqliteDb) GetTags(ctx context.Context) ([]TagCount, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT tag, COUNT(*) FROM thread_tags
+		JOIN threads ON threads.id = thread_tags.thread_id
+		WHERE threads.deleted_at IS NULL
+		GROUP BY tag`)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	counts := []TagCount{}
+	for rows.Next() {
+		var count TagCount
+		if err := rows.Scan(&count.Tag, &count.Count); err != nil {
+			return nil, internalError(err)
+		}
+		counts = append(counts, count)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	sortTagCounts(counts)
+	return counts, nil
+}
+
+const webhookColumns = `id, url, events, secret, created`
+
+func scanWebhook(row rowScanner) (Webhook, error) {
+	var (
+		w       Webhook
+		events  string
+		created int64
+	)
+	if err := row.Scan(&w.ID, &w.URL, &events, &w.Secret, &created); err != nil {
+		return Webhook{}, err
+	}
+	if err := json.Unmarshal([]byte(events), &w.Events); err != nil {
+		return Webhook{}, err
+	}
+	w.Created = time.Unix(0, created)
+	return w, nil
+}
+
+func (s *SqliteDb) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT `+webhookColumns+` FROM webhooks ORDER BY created, id`)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	webhooks := []Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return webhooks, nil
+}
+
+func (s *SqliteDb) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	return getWebhook(ctx, s.db, id)
+}
+
+func getWebhook(ctx context.Context, q queryRower, id string) (Webhook, error) {
+	webhook, err := scanWebhook(q.QueryRowContext(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE id = ?`, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	if err != nil {
+		return Webhook{}, internalError(err)
+	}
+	return webhook, nil
+}
+
+func (s *SqliteDb) AddWebhook(ctx context.Context, url string, events []string, secret string) (Webhook, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Webhook{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	id, err := nextID(ctx, tx, "webhooks")
+	if err != nil {
+		return Webhook{}, internalError(err)
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		return Webhook{}, internalError(err)
+	}
+
+	webhook := Webhook{
+		ID:      id,
+		URL:     url,
+		Events:  events,
+		Secret:  secret,
+		Created: time.Unix(0, time.Now().UnixNano()),
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO webhooks (`+webhookColumns+`) VALUES (?, ?, ?, ?, ?)`,
+		webhook.ID, webhook.URL, string(data), webhook.Secret, webhook.Created.UnixNano(),
+	); err != nil {
+		return Webhook{}, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Webhook{}, internalError(err)
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook removes the webhook, its deliveries go with it through the
+// foreign key.
+func (s *SqliteDb) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM webhooks WHERE id = ?`, id)
+	if err != nil {
+		return internalError(err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return internalError(err)
+	} else if n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+const deliveryColumns = `id, webhook_id, event, payload, status, attempts, next_attempt, response_status, last_error, created, last_update`
+
+func scanDelivery(row rowScanner) (Delivery, error) {
+	var (
+		d                                Delivery
+		payload                          string
+		nextAttempt, created, lastUpdate int64
+	)
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.Event, &payload, &d.Status, &d.Attempts, &nextAttempt, &d.ResponseStatus, &d.LastError, &created, &lastUpdate); err != nil {
+		return Delivery{}, err
+	}
+	d.Payload = json.RawMessage(payload)
+	d.NextAttempt = time.Unix(0, nextAttempt)
+	d.Created = time.Unix(0, created)
+	d.LastUpdate = time.Unix(0, lastUpdate)
+	return d, nil
+}
+
+func (s *SqliteDb) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]Delivery, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	deliveries := []Delivery{}
+	for rows.Next() {
+		delivery, err := scanDelivery(rows)
+		if err != nil {
+			return nil, internalError(err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return deliveries, nil
+}
+
+func (s *SqliteDb) GetDeliveries(ctx context.Context, webhookID string, status string) ([]Delivery, error) {
+	if webhookID != "" {
+		if _, err := s.GetWebhook(ctx, webhookID); err != nil {
+			return nil, err
+		}
+	}
+
+	where := []string{`1 = 1`}
+	args := []interface{}{}
+	if webhookID != "" {
+		where = append(where, `webhook_id = ?`)
+		args = append(args, webhookID)
+	}
+	if status != "" {
+		where = append(where, `status = ?`)
+		args = append(args, status)
+	}
+	return s.queryDeliveries(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE `+strings.Join(where, ` AND `)+` ORDER BY created, id`,
+		args...,
+	)
+}
+
+func (s *SqliteDb) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	return s.queryDeliveries(ctx,
+		`SELECT `+deliveryColumns+` FROM webhook_deliveries
+		WHERE status = ? AND next_attempt <= ?
+		ORDER BY next_attempt, id LIMIT ?`,
+		DeliveryPending, now.UnixNano(), pageSize(limit),
+	)
+}
+
+func (s *SqliteDb) AddDelivery(ctx context.Context, webhookID string, event string, payload []byte) (Delivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Delivery{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := getWebhook(ctx, tx, webhookID); err != nil {
+		return Delivery{}, err
+	}
+	id, err := nextID(ctx, tx, "deliveries")
+	if err != nil {
+		return Delivery{}, internalError(err)
+	}
+
+	now := time.Unix(0, time.Now().UnixNano())
+	delivery := Delivery{
+		ID:          id,
+		WebhookID:   webhookID,
+		Event:       event,
+		Payload:     payload,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+		LastUpdate:  now,
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (`+deliveryColumns+`) VALUES (?, ?, ?, ?, ?, 0, ?, 0, '', ?, ?)`,
+		delivery.ID, delivery.WebhookID, delivery.Event, string(payload), delivery.Status,
+		now.UnixNano(), now.UnixNano(), now.UnixNano(),
+	); err != nil {
+		return Delivery{}, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Delivery{}, internalError(err)
+	}
+	return delivery, nil
+}
+
+func (s *SqliteDb) UpdateDelivery(ctx context.Context, delivery Delivery) (Delivery, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Delivery{}, internalError(err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt = ?, response_status = ?, last_error = ?, last_update = ?
+		WHERE id = ?`,
+		delivery.Status, delivery.Attempts, delivery.NextAttempt.UnixNano(), delivery.ResponseStatus, delivery.LastError,
+		time.Now().UnixNano(), delivery.ID,
+	); err != nil {
+		return Delivery{}, internalError(err)
+	}
+
+	updated, err := scanDelivery(tx.QueryRowContext(ctx, `SELECT `+deliveryColumns+` FROM webhook_deliveries WHERE id = ?`, delivery.ID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+	if err != nil {
+		return Delivery{}, internalError(err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Delivery{}, internalError(err)
+	}
+	return updated, nil
+}
+
+// record adds the event of the write made by tx to the outbox, it is only
+// seen once tx commits.
+func record(ctx context.Context, tx *sql.Tx, eventType string, threadID string, data interface{}) error {
+	entry, err := newOutboxEntry(eventType, threadID, data)
+	if err != nil {
+		return err
+	}
+	var raw sql.NullString
+	if entry.Data != nil {
+		raw = sql.NullString{String: string(entry.Data), Valid: true}
+	}
+	if _, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (key, type, thread_id, data, created) VALUES (?, ?, ?, ?, ?)`,
+		entry.Key, entry.Type, entry.ThreadID, raw, entry.Created.UnixNano(),
+	); err != nil {
+		return internalError(err)
+	}
+	return nil
+}
+
+func (s *SqliteDb) GetOutbox(ctx context.Context, limit int) ([]OutboxEntry, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, key, type, thread_id, data, created FROM outbox ORDER BY id LIMIT ?`,
+		pageSize(limit),
+	)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	defer rows.Close()
+
+	entries := []OutboxEntry{}
+	for rows.Next() {
+		var (
+			entry   OutboxEntry
+			data    sql.NullString
+			created int64
+		)
+		if err := rows.Scan(&entry.ID, &entry.Key, &entry.Type, &entry.ThreadID, &data, &created); err != nil {
+			return nil, internalError(err)
+		}
+		if data.Valid {
+			entry.Data = json.RawMessage(data.String)
+		}
+		entry.Created = time.Unix(0, created)
+		entries = append(entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, internalError(err)
+	}
+	return entries, nil
+}
+
+func (s *SqliteDb) AckOutbox(ctx context.Context, ids ...int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return internalError(err)
+	}
+	defer tx.Rollback()
+
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM outbox WHERE id = ?`, id); err != nil {
+			return internalError(err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return internalError(err)
+	}
+	return nil
+}
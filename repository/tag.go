@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+)
+
+// TagCount is how many threads outside the trash carry a tag.
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+// normalizeTags lowercases tags, drops the blank and duplicate ones and
+// sorts the rest.
+func normalizeTags(tags []string) []string {
+	seen := make(map[string]bool, len(tags))
+	normalized := []string{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// sortTagCounts puts the most used tags first.
+func sortTagCounts(counts []TagCount) {
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].Tag < counts[j].Tag
+	})
+}
+
+// GetTags returns every tag in use with the number of threads carrying it,
+// most used first.
+func (db *Db) GetTags(ctx context.Context) ([]TagCount, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	counts := make([]TagCount, 0, len(db.tags))
+	for tag, ids := range db.tags {
+		counts = append(counts, TagCount{Tag: tag, Count: len(ids)})
+	}
+	sortTagCounts(counts)
+	return counts, nil
+}
+
+func (db *Db) tag(thread Thread) {
+	for _, tag := range thread.Tags {
+		if db.tags[tag] == nil {
+			db.tags[tag] = make(map[string]bool)
+		}
+		db.tags[tag][thread.ID] = true
+	}
+}
+
+func (db *Db) untag(thread Thread) {
+	for _, tag := range thread.Tags {
+		delete(db.tags[tag], thread.ID)
+		if len(db.tags[tag]) == 0 {
+			delete(db.tags, tag)
+		}
+	}
+}
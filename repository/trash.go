@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// GetTrash returns the deleted threads that were not purged yet, most
+// recently deleted first.
+func (db *Db) GetTrash(ctx context.Context) ([]Thread, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	t := make([]Thread, 0, len(db.trash))
+	for _, thread := range db.trash {
+		t = append(t, thread)
+	}
+	sortTrash(t)
+	return t, nil
+}
+
+func sortTrash(t []Thread) {
+	sort.Slice(t, func(i, j int) bool {
+		if t[i].DeletedAt.Equal(*t[j].DeletedAt) {
+			return t[i].ID > t[j].ID
+		}
+		return t[i].DeletedAt.After(*t[j].DeletedAt)
+	})
+}
+
+func (db *Db) trashed(id string) (Thread, bool) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	thread, ok := db.trash[id]
+	return thread, ok
+}
+
+// RestoreThread takes a thread out of the trash as it was when deleted.
+func (db *Db) RestoreThread(ctx context.Context, id string) (Thread, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Thread{}, err
+	}
+
+	val, ok := db.trash[id]
+	if !ok {
+		return Thread{}, ErrThreadNotFound
+	}
+
+	val.DeletedAt = nil
+	db.putThread(val)
+	return val, nil
+}
+
+// PurgeThreads permanently removes the threads deleted before the given
+// time along with their revisions, replies and reactions, and returns how many were removed.
+func (db *Db) PurgeThreads(ctx context.Context, before time.Time) (int, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for id, thread := range db.trash {
+		if !thread.DeletedAt.Before(before) {
+			continue
+		}
+		delete(db.trash, id)
+		delete(db.revisions, id)
+		delete(db.replies, id)
+		delete(db.reactions, id)
+		purged++
+	}
+	return purged, nil
+}
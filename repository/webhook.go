@@ -0,0 +1,273 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+var (
+	ErrWebhookNotFound  = fmt.Errorf("webhook %w", ErrNotFound)
+	ErrDeliveryNotFound = fmt.Errorf("delivery %w", ErrNotFound)
+)
+
+// Delivery states. A pending delivery is attempted until it is delivered or
+// runs out of attempts, it is then dead and left for inspection.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Webhook is a URL told about the thread events it subscribed to.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// Secret signs the payloads sent to URL
+	Secret  string    `json:"secret,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// Subscribes reports whether the webhook wants events of eventType.
+func (w Webhook) Subscribes(eventType string) bool {
+	for _, e := range w.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Delivery is one event queued for a webhook along with the outcome of its
+// attempts.
+type Delivery struct {
+	ID          string          `json:"id"`
+	WebhookID   string          `json:"webhook_id"`
+	Event       string          `json:"event"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      string          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	NextAttempt time.Time       `json:"next_attempt"`
+	// ResponseStatus and LastError describe the last failed attempt
+	ResponseStatus int       `json:"response_status,omitempty"`
+	LastError      string    `json:"last_error,omitempty"`
+	Created        time.Time `json:"created"`
+	LastUpdate     time.Time `json:"last_update"`
+}
+
+func sortWebhooks(webhooks []Webhook) {
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].Created.Equal(webhooks[j].Created) {
+			return webhooks[i].Created.Before(webhooks[j].Created)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+}
+
+func (db *Db) GetWebhooks(ctx context.Context) ([]Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]Webhook, 0, len(db.webhooks))
+	for _, webhook := range db.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+	sortWebhooks(webhooks)
+	return webhooks, nil
+}
+
+func (db *Db) GetWebhook(ctx context.Context, id string) (Webhook, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Webhook{}, err
+	}
+
+	webhook, ok := db.webhooks[id]
+	if !ok {
+		return Webhook{}, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+func (db *Db) AddWebhook(ctx context.Context, url string, events []string, secret string) (Webhook, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Webhook{}, err
+	}
+
+	webhook := Webhook{
+		ID:      strconv.Itoa(db.webhookIncrement),
+		URL:     url,
+		Events:  events,
+		Secret:  secret,
+		Created: time.Now(),
+	}
+	db.setWebhook(webhook)
+	return webhook, nil
+}
+
+// DeleteWebhook forgets the webhook along with its deliveries.
+func (db *Db) DeleteWebhook(ctx context.Context, id string) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return err
+	}
+
+	if _, ok := db.webhooks[id]; !ok {
+		return ErrWebhookNotFound
+	}
+	db.unsetWebhook(id)
+	return nil
+}
+
+// GetDeliveries lists the deliveries of a webhook, or of every webhook when
+// webhookID is empty, oldest first. A non empty status only keeps the
+// deliveries in that state.
+func (db *Db) GetDeliveries(ctx context.Context, webhookID string, status string) ([]Delivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	if _, ok := db.webhooks[webhookID]; webhookID != "" && !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	deliveries := []Delivery{}
+	for _, delivery := range db.deliveries {
+		if webhookID != "" && delivery.WebhookID != webhookID {
+			continue
+		}
+		if status != "" && delivery.Status != status {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].Created.Equal(deliveries[j].Created) {
+			return deliveries[i].Created.Before(deliveries[j].Created)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	return deliveries, nil
+}
+
+// DueDeliveries returns up to limit pending deliveries whose next attempt
+// is not after now, the most overdue first.
+func (db *Db) DueDeliveries(ctx context.Context, now time.Time, limit int) ([]Delivery, error) {
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	if err := checkContext(ctx); err != nil {
+		return nil, err
+	}
+
+	deliveries := []Delivery{}
+	for _, delivery := range db.deliveries {
+		if delivery.Status == DeliveryPending && !delivery.NextAttempt.After(now) {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].NextAttempt.Equal(deliveries[j].NextAttempt) {
+			return deliveries[i].NextAttempt.Before(deliveries[j].NextAttempt)
+		}
+		return deliveries[i].ID < deliveries[j].ID
+	})
+	if len(deliveries) > pageSize(limit) {
+		deliveries = deliveries[:pageSize(limit)]
+	}
+	return deliveries, nil
+}
+
+// AddDelivery queues payload for the webhook, it is due right away.
+func (db *Db) AddDelivery(ctx context.Context, webhookID string, event string, payload []byte) (Delivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Delivery{}, err
+	}
+
+	if _, ok := db.webhooks[webhookID]; !ok {
+		return Delivery{}, ErrWebhookNotFound
+	}
+
+	now := time.Now()
+	delivery := Delivery{
+		ID:          strconv.Itoa(db.deliveryIncrement),
+		WebhookID:   webhookID,
+		Event:       event,
+		Payload:     payload,
+		Status:      DeliveryPending,
+		NextAttempt: now,
+		Created:     now,
+		LastUpdate:  now,
+	}
+	db.setDelivery(delivery)
+	return delivery, nil
+}
+
+// UpdateDelivery stores the outcome of an attempt, the status, attempts,
+// next attempt and last error of delivery replace the stored ones.
+func (db *Db) UpdateDelivery(ctx context.Context, delivery Delivery) (Delivery, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if err := checkContext(ctx); err != nil {
+		return Delivery{}, err
+	}
+
+	val, ok := db.deliveries[delivery.ID]
+	if !ok {
+		return Delivery{}, ErrDeliveryNotFound
+	}
+
+	val.Status = delivery.Status
+	val.Attempts = delivery.Attempts
+	val.NextAttempt = delivery.NextAttempt
+	val.ResponseStatus = delivery.ResponseStatus
+	val.LastError = delivery.LastError
+	val.LastUpdate = time.Now()
+	db.setDelivery(val)
+	return val, nil
+}
+
+func (db *Db) setWebhook(webhook Webhook) {
+	db.webhooks[webhook.ID] = webhook
+	if n, err := strconv.Atoi(webhook.ID); err == nil && n >= db.webhookIncrement {
+		db.webhookIncrement = n + 1
+	}
+}
+
+func (db *Db) unsetWebhook(id string) {
+	delete(db.webhooks, id)
+	for deliveryID, delivery := range db.deliveries {
+		if delivery.WebhookID == id {
+			delete(db.deliveries, deliveryID)
+		}
+	}
+}
+
+func (db *Db) setDelivery(delivery Delivery) {
+	db.deliveries[delivery.ID] = delivery
+	if n, err := strconv.Atoi(delivery.ID); err == nil && n >= db.deliveryIncrement {
+		db.deliveryIncrement = n + 1
+	}
+}
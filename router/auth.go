@@ -0,0 +1,23 @@
+package router
+
+import "github.com/gofiber/fiber/v2"
+
+type AuthRouterImplementation interface {
+	Login(c *fiber.Ctx) error
+}
+
+type AuthRoute struct {
+	AuthRouterImplementation
+	timeouts
+}
+
+func NewAuthRoute(r AuthRouterImplementation, opts ...RouteOption) *AuthRoute {
+	return &AuthRoute{
+		AuthRouterImplementation: r,
+		timeouts:                 newTimeouts(opts),
+	}
+}
+
+func (ar *AuthRoute) Route(app fiber.Router) {
+	app.Post("/auth/login", ar.writes(), ar.Login)
+}
@@ -0,0 +1,47 @@
+package router
+
+import (
+	midware "gofiber-api/middleware"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RouteOption func(*timeouts)
+
+// timeouts bound the handlers of the routes, reads and writes apart. A zero
+// timeout leaves them unbounded.
+type timeouts struct {
+	read  time.Duration
+	write time.Duration
+}
+
+// WithReadTimeout bounds the handlers of the GET routes.
+func WithReadTimeout(timeout time.Duration) RouteOption {
+	return func(t *timeouts) {
+		t.read = timeout
+	}
+}
+
+// WithWriteTimeout bounds the handlers of the routes changing something.
+func WithWriteTimeout(timeout time.Duration) RouteOption {
+	return func(t *timeouts) {
+		t.write = timeout
+	}
+}
+
+func newTimeouts(opts []RouteOption) timeouts {
+	t := timeouts{}
+	for _, opt := range opts {
+		opt(&t)
+	}
+	return t
+}
+
+func (t timeouts) reads() fiber.Handler {
+	return midware.Timeout(t.read)
+}
+
+func (t timeouts) writes() fiber.Handler {
+	return midware.Timeout(t.write)
+}
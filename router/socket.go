@@ -0,0 +1,22 @@
+package router
+
+import "github.com/gofiber/fiber/v2"
+
+type SocketRouterImplementation interface {
+	UpgradeThreadSocket(c *fiber.Ctx) error
+	ThreadSocket(c *fiber.Ctx) error
+}
+
+type SocketRoute struct {
+	SocketRouterImplementation
+}
+
+func NewSocketRoute(r SocketRouterImplementation) *SocketRoute {
+	return &SocketRoute{
+		SocketRouterImplementation: r,
+	}
+}
+
+func (sr *SocketRoute) Route(app fiber.Router) {
+	app.Get("/threads/:id/ws", sr.UpgradeThreadSocket, sr.ThreadSocket)
+}
@@ -0,0 +1,23 @@
+package router
+
+import "github.com/gofiber/fiber/v2"
+
+type StreamRouterImplementation interface {
+	StreamThreads(c *fiber.Ctx) error
+}
+
+type StreamRoute struct {
+	StreamRouterImplementation
+}
+
+func NewStreamRoute(r StreamRouterImplementation) *StreamRoute {
+	return &StreamRoute{
+		StreamRouterImplementation: r,
+	}
+}
+
+// Route must be called before the thread routes, /threads/:id would match
+// the stream otherwise.
+func (sr *StreamRoute) Route(app fiber.Router) {
+	app.Get("/threads/stream", sr.StreamThreads)
+}
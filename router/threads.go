@@ -4,24 +4,62 @@ import "github.com/gofiber/fiber/v2"
 
 type RouterImplementation interface {
 	GetAllThreads(c *fiber.Ctx) error
+	GetThread(c *fiber.Ctx) error
+	SearchThreads(c *fiber.Ctx) error
 	CreateThread(c *fiber.Ctx) error
 	EditThread(c *fiber.Ctx) error
 	DeleteThread(c *fiber.Ctx) error
+	GetThreadRevisions(c *fiber.Ctx) error
+	GetThreadRevision(c *fiber.Ctx) error
+	RestoreThreadRevision(c *fiber.Ctx) error
+	GetTrash(c *fiber.Ctx) error
+	RestoreThread(c *fiber.Ctx) error
+	GetReplies(c *fiber.Ctx) error
+	GetReply(c *fiber.Ctx) error
+	GetReplyTree(c *fiber.Ctx) error
+	CreateReply(c *fiber.Ctx) error
+	EditReply(c *fiber.Ctx) error
+	DeleteReply(c *fiber.Ctx) error
+	AddReaction(c *fiber.Ctx) error
+	RemoveReaction(c *fiber.Ctx) error
+	GetTags(c *fiber.Ctx) error
 }
 
 type ThreadRoute struct {
 	RouterImplementation
+	timeouts
 }
 
-func NewThreadRoute(r RouterImplementation) *ThreadRoute {
+func NewThreadRoute(r RouterImplementation, opts ...RouteOption) *ThreadRoute {
 	return &ThreadRoute{
 		RouterImplementation: r,
+		timeouts:             newTimeouts(opts),
 	}
 }
 
 func (tr *ThreadRoute) Route(app fiber.Router) {
-	app.Get("/threads", tr.GetAllThreads)
-	app.Post("/threads", tr.CreateThread)
-	app.Put("/threads/:id", tr.EditThread)
-	app.Delete("/threads/:id", tr.DeleteThread)
+	read := tr.reads()
+	write := tr.writes()
+
+	app.Get("/threads", read, tr.GetAllThreads)
+	app.Get("/threads/search", read, tr.SearchThreads)
+	app.Get("/threads/:id", read, tr.GetThread)
+	app.Post("/threads", write, tr.CreateThread)
+	app.Put("/threads/:id", write, tr.EditThread)
+	app.Delete("/threads/:id", write, tr.DeleteThread)
+	app.Get("/threads/:id/revisions", read, tr.GetThreadRevisions)
+	app.Get("/threads/:id/revisions/:rev", read, tr.GetThreadRevision)
+	app.Post("/threads/:id/revisions/:rev/restore", write, tr.RestoreThreadRevision)
+	app.Post("/threads/:id/restore", write, tr.RestoreThread)
+	app.Get("/threads/:id/replies", read, tr.GetReplies)
+	app.Get("/threads/:id/replies/tree", read, tr.GetReplyTree)
+	app.Get("/threads/:id/replies/:replyId/tree", read, tr.GetReplyTree)
+	app.Get("/threads/:id/replies/:replyId", read, tr.GetReply)
+	app.Post("/threads/:id/replies", write, tr.CreateReply)
+	app.Put("/threads/:id/replies/:replyId", write, tr.EditReply)
+	app.Delete("/threads/:id/replies/:replyId", write, tr.DeleteReply)
+	app.Put("/threads/:id/reactions/:kind", write, tr.AddReaction)
+	app.Delete("/threads/:id/reactions/:kind", write, tr.RemoveReaction)
+	app.Get("/trash", read, tr.GetTrash)
+	app.Get("/tags", read, tr.GetTags)
 }
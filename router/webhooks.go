@@ -0,0 +1,36 @@
+package router
+
+import "github.com/gofiber/fiber/v2"
+
+type WebhookRouterImplementation interface {
+	GetWebhooks(c *fiber.Ctx) error
+	GetWebhook(c *fiber.Ctx) error
+	CreateWebhook(c *fiber.Ctx) error
+	DeleteWebhook(c *fiber.Ctx) error
+	GetDeliveries(c *fiber.Ctx) error
+	GetDeadLetters(c *fiber.Ctx) error
+}
+
+type WebhookRoute struct {
+	WebhookRouterImplementation
+	timeouts
+}
+
+func NewWebhookRoute(r WebhookRouterImplementation, opts ...RouteOption) *WebhookRoute {
+	return &WebhookRoute{
+		WebhookRouterImplementation: r,
+		timeouts:                    newTimeouts(opts),
+	}
+}
+
+func (wr *WebhookRoute) Route(app fiber.Router) {
+	read := wr.reads()
+	write := wr.writes()
+
+	app.Get("/webhooks", read, wr.GetWebhooks)
+	app.Post("/webhooks", write, wr.CreateWebhook)
+	app.Get("/webhooks/dead-letters", read, wr.GetDeadLetters)
+	app.Get("/webhooks/:id", read, wr.GetWebhook)
+	app.Delete("/webhooks/:id", write, wr.DeleteWebhook)
+	app.Get("/webhooks/:id/deliveries", read, wr.GetDeliveries)
+}
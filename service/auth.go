@@ -0,0 +1,34 @@
+package threads
+
+import (
+	"context"
+	"gofiber-api/auth"
+)
+
+type UserAuthenticator interface {
+	Authenticate(username string, password string) (auth.Identity, error)
+}
+
+type TokenIssuer interface {
+	Issue(identity auth.Identity) (auth.Token, error)
+}
+
+type AuthService struct {
+	UserAuthenticator
+	TokenIssuer
+}
+
+func NewAuth(users UserAuthenticator, issuer TokenIssuer) *AuthService {
+	return &AuthService{
+		UserAuthenticator: users,
+		TokenIssuer:       issuer,
+	}
+}
+
+func (a *AuthService) Login(ctx context.Context, username string, password string) (auth.Token, error) {
+	identity, err := a.Authenticate(username, password)
+	if err != nil {
+		return auth.Token{}, err
+	}
+	return a.Issue(identity)
+}
@@ -0,0 +1,265 @@
+package threads
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gofiber-api/events"
+	repo "gofiber-api/repository"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	DefaultMaxAttempts     = 8
+	DefaultRetryBackoff    = 10 * time.Second
+	DefaultMaxRetryBackoff = time.Hour
+	DefaultPollInterval    = 5 * time.Second
+
+	deliveryTimeout = 10 * time.Second
+	dispatchBatch   = 100
+)
+
+// Headers sent along with every webhook payload. The signature is the hex
+// HMAC-SHA256 of the body keyed with the secret of the webhook, prefixed
+// with "sha256=".
+const (
+	HeaderWebhookEvent     = "X-Webhook-Event"
+	HeaderWebhookDelivery  = "X-Webhook-Delivery"
+	HeaderWebhookSignature = "X-Webhook-Signature"
+)
+
+// WebhookPayload is the JSON body posted to webhooks.
+type WebhookPayload struct {
+	Key      string      `json:"key"`
+	Event    string      `json:"event"`
+	ThreadID string      `json:"thread_id"`
+	Data     interface{} `json:"data,omitempty"`
+	Occurred time.Time   `json:"occurred"`
+}
+
+// Sign returns the signature of body sent in HeaderWebhookSignature.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Dispatcher queues the published events for the webhooks subscribed to
+// them and posts them. A failed delivery is retried with an exponential
+// backoff until it runs out of attempts and is marked dead.
+type Dispatcher struct {
+	RepositoryWebhook
+	client       *http.Client
+	maxAttempts  int
+	backoff      time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	// wake tells Run that deliveries were queued
+	wake chan struct{}
+}
+
+type DispatcherOption func(*Dispatcher)
+
+func WithHTTPClient(client *http.Client) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.client = client
+	}
+}
+
+// WithMaxAttempts sets how many times a delivery is attempted before it is
+// marked dead.
+func WithMaxAttempts(attempts int) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.maxAttempts = attempts
+	}
+}
+
+// WithRetryBackoff sets the delay before the first retry, it doubles on
+// every failed attempt up to max.
+func WithRetryBackoff(base time.Duration, max time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.backoff = base
+		d.maxBackoff = max
+	}
+}
+
+// WithPollInterval sets how often Run looks for deliveries due for a retry.
+func WithPollInterval(interval time.Duration) DispatcherOption {
+	return func(d *Dispatcher) {
+		d.pollInterval = interval
+	}
+}
+
+func NewDispatcher(r RepositoryWebhook, opts ...DispatcherOption) *Dispatcher {
+	d := &Dispatcher{
+		RepositoryWebhook: r,
+		client:            &http.Client{Timeout: deliveryTimeout},
+		maxAttempts:       DefaultMaxAttempts,
+		backoff:           DefaultRetryBackoff,
+		maxBackoff:        DefaultMaxRetryBackoff,
+		pollInterval:      DefaultPollInterval,
+		wake:              make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Send queues event for every webhook subscribed to its type, the
+// deliveries are made by Run. The key of the event goes along in the
+// payload so receivers can drop the copies of a resent event.
+func (d *Dispatcher) Send(ctx context.Context, event events.Event) error {
+	webhooks, err := d.GetWebhooks(ctx)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(WebhookPayload{
+		Key:      event.Key,
+		Event:    event.Type,
+		ThreadID: event.ThreadID,
+		Data:     event.Data,
+		Occurred: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+
+	queued := false
+	for _, webhook := range webhooks {
+		if !webhook.Subscribes(event.Type) {
+			continue
+		}
+		if _, err := d.AddDelivery(ctx, webhook.ID, event.Type, payload); err != nil {
+			// the webhook went away since it was listed
+			if errors.Is(err, repo.ErrWebhookNotFound) {
+				continue
+			}
+			return err
+		}
+		queued = true
+	}
+
+	if queued {
+		select {
+		case d.wake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// Dispatch attempts the deliveries that are due once and returns how many
+// were attempted.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	due, err := d.DueDeliveries(ctx, time.Now(), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range due {
+		if _, err := d.UpdateDelivery(ctx, d.attempt(ctx, delivery)); err != nil {
+			return 0, err
+		}
+	}
+	return len(due), nil
+}
+
+// Run dispatches the deliveries as they are queued and polls for the
+// retries until ctx is done.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := d.Dispatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("dispatching webhooks: %v", err)
+		}
+		// a full batch means more are waiting
+		if n == dispatchBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+// attempt posts the delivery to its webhook and returns it updated with
+// the outcome.
+func (d *Dispatcher) attempt(ctx context.Context, delivery repo.Delivery) repo.Delivery {
+	delivery.Attempts++
+
+	status, err := d.post(ctx, delivery)
+	if err == nil {
+		delivery.Status = repo.DeliveryDelivered
+		delivery.ResponseStatus = status
+		delivery.LastError = ""
+		return delivery
+	}
+
+	delivery.ResponseStatus = status
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= d.maxAttempts || errors.Is(err, repo.ErrWebhookNotFound) {
+		delivery.Status = repo.DeliveryDead
+		return delivery
+	}
+	delivery.NextAttempt = time.Now().Add(d.retryAfter(delivery.Attempts))
+	return delivery
+}
+
+func (d *Dispatcher) post(ctx context.Context, delivery repo.Delivery) (int, error) {
+	webhook, err := d.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderWebhookEvent, delivery.Event)
+	req.Header.Set(HeaderWebhookDelivery, delivery.ID)
+	req.Header.Set(HeaderWebhookSignature, Sign(webhook.Secret, delivery.Payload))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// drain the body so the connection is reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryAfter is the delay before the retry following the given number of
+// failed attempts.
+func (d *Dispatcher) retryAfter(attempts int) time.Duration {
+	delay := d.backoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.maxBackoff {
+		delay = d.maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,53 @@
+package threads
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+type RepositoryTrash interface {
+	PurgeThreads(ctx context.Context, before time.Time) (int, error)
+}
+
+// Purger permanently removes the threads that stayed in the trash longer
+// than the retention period.
+type Purger struct {
+	RepositoryTrash
+	retention time.Duration
+	interval  time.Duration
+}
+
+func NewPurger(r RepositoryTrash, retention time.Duration, interval time.Duration) *Purger {
+	return &Purger{
+		RepositoryTrash: r,
+		retention:       retention,
+		interval:        interval,
+	}
+}
+
+// Purge removes the expired threads once and returns how many were removed.
+func (p *Purger) Purge(ctx context.Context) (int, error) {
+	return p.PurgeThreads(ctx, time.Now().Add(-p.retention))
+}
+
+// Run purges every interval until ctx is done.
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := p.Purge(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("purging trash: %v", err)
+			}
+			if n > 0 {
+				log.Printf("purged %d threads from the trash", n)
+			}
+		}
+	}
+}
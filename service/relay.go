@@ -0,0 +1,130 @@
+package threads
+
+import (
+	"context"
+	"gofiber-api/events"
+	repo "gofiber-api/repository"
+	"log"
+	"time"
+)
+
+const (
+	DefaultRelayInterval = time.Second
+
+	relayBatch = 100
+)
+
+type RepositoryOutbox interface {
+	GetOutbox(ctx context.Context, limit int) ([]repo.OutboxEntry, error)
+	AckOutbox(ctx context.Context, ids ...int64) error
+}
+
+// Sink receives the events relayed from the outbox. An event is sent again
+// until every sink accepted it, so a sink may see an event more than once
+// and tells the copies apart by Event.Key.
+type Sink interface {
+	Send(ctx context.Context, event events.Event) error
+}
+
+// Relay drains the outbox of the store to the sinks. An entry is only
+// acknowledged once every sink accepted it, entries are relayed in order
+// and a failing sink holds back the ones after it.
+type Relay struct {
+	RepositoryOutbox
+	sinks    []Sink
+	interval time.Duration
+	// wake tells Run that entries were recorded
+	wake chan struct{}
+}
+
+type RelayOption func(*Relay)
+
+func WithSink(sink Sink) RelayOption {
+	return func(r *Relay) {
+		r.sinks = append(r.sinks, sink)
+	}
+}
+
+// WithRelayInterval sets how often Run looks at the outbox when it is not
+// notified, it bounds the delay of a retry after a sink failed.
+func WithRelayInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+func NewRelay(r RepositoryOutbox, opts ...RelayOption) *Relay {
+	relay := &Relay{
+		RepositoryOutbox: r,
+		interval:         DefaultRelayInterval,
+		wake:             make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(relay)
+	}
+	return relay
+}
+
+// Notify tells the relay that entries were recorded, it never blocks.
+func (r *Relay) Notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Drain relays the entries of the outbox once and returns how many were
+// acknowledged.
+func (r *Relay) Drain(ctx context.Context) (int, error) {
+	entries, err := r.GetOutbox(ctx, relayBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, entry := range entries {
+		event := events.Event{
+			Key:      entry.Key,
+			Type:     entry.Type,
+			ThreadID: entry.ThreadID,
+		}
+		if entry.Data != nil {
+			event.Data = entry.Data
+		}
+
+		for _, sink := range r.sinks {
+			if err := sink.Send(ctx, event); err != nil {
+				return i, err
+			}
+		}
+		if err := r.AckOutbox(ctx, entry.ID); err != nil {
+			return i, err
+		}
+	}
+	return len(entries), nil
+}
+
+// Run drains the outbox whenever it is notified or every interval until
+// ctx is done.
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := r.Drain(ctx)
+		// failures from being stopped are not worth reporting
+		if err != nil && ctx.Err() == nil {
+			log.Printf("relaying outbox: %v", err)
+		}
+		// a full batch means more are waiting
+		if err == nil && n == relayBatch {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-r.wake:
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package threads_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/events"
+	repo "gofiber-api/repository"
+	service "gofiber-api/service"
+)
+
+// flakySink fails the send numbered failAt, counting from 1, and accepts
+// every other one.
+type flakySink struct {
+	failAt int
+	sends  int
+	sent   []events.Event
+}
+
+var errSinkDown = errors.New("sink down")
+
+func (f *flakySink) Send(ctx context.Context, event events.Event) error {
+	f.sends++
+	f.sent = append(f.sent, event)
+	if f.sends == f.failAt {
+		return errSinkDown
+	}
+	return nil
+}
+
+type RelaySuite struct {
+	suite.Suite
+	db    *repo.Db
+	sink  *flakySink
+	relay *service.Relay
+}
+
+func TestRelaySuite(t *testing.T) {
+	suite.Run(t, new(RelaySuite))
+}
+
+func (s *RelaySuite) SetupTest() {
+	s.db = &repo.Db{}
+	s.db.Init()
+	s.sink = &flakySink{failAt: 2}
+	s.relay = service.NewRelay(s.db, service.WithSink(s.sink))
+
+	ctx := context.Background()
+	for _, content := range []string{"first", "second", "third"} {
+		_, err := s.db.AddThread(ctx, "the-author", content)
+		s.Require().NoError(err)
+	}
+}
+
+func (s *RelaySuite) outbox() []repo.OutboxEntry {
+	entries, err := s.db.GetOutbox(context.Background(), 10)
+	s.Require().NoError(err)
+	return entries
+}
+
+func (s *RelaySuite) TestDrainRetriesFailedEntry() {
+	ctx := context.Background()
+	recorded := s.outbox()
+	s.Require().Len(recorded, 3)
+
+	n, err := s.relay.Drain(ctx)
+	s.ErrorIs(err, errSinkDown)
+	s.Equal(1, n)
+	// the failed entry stays and holds back the one after it
+	s.Require().Len(s.sink.sent, 2)
+	s.Equal(recorded[0].Key, s.sink.sent[0].Key)
+	s.Equal(recorded[1].Key, s.sink.sent[1].Key)
+	s.Equal(recorded[1:], s.outbox())
+
+	n, err = s.relay.Drain(ctx)
+	s.NoError(err)
+	s.Equal(2, n)
+	s.Require().Len(s.sink.sent, 4)
+	s.Equal(recorded[1].Key, s.sink.sent[2].Key)
+	s.Equal(recorded[2].Key, s.sink.sent[3].Key)
+	s.Empty(s.outbox())
+}
@@ -0,0 +1,79 @@
+package threads
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gofiber-api/auth"
+	repo "gofiber-api/repository"
+)
+
+// authorizeReply checks that actor may act on the reply, either as its
+// author or through a permission of their role. Replies are moderated with
+// the same permissions as threads.
+func (t *ThreadService) authorizeReply(ctx context.Context, actor auth.Identity, threadID string, replyID string, permission string) error {
+	reply, err := t.GetReply(ctx, threadID, replyID)
+	if err != nil {
+		return err
+	}
+	if reply.Author != actor.Username && !t.policy.Allows(actor.Role, permission) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+func (t *ThreadService) Replies(ctx context.Context, threadID string) ([]repo.Reply, error) {
+	return t.GetReplies(ctx, threadID)
+}
+
+func (t *ThreadService) Reply(ctx context.Context, threadID string, replyID string) (repo.Reply, error) {
+	return t.GetReply(ctx, threadID, replyID)
+}
+
+// ReplyTree returns the replies of a thread nested under their parent, or
+// only the subtree of rootID when it is not empty. A positive depth limits
+// how many levels are loaded.
+func (t *ThreadService) ReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]repo.ReplyNode, error) {
+	replies, err := t.GetReplyTree(ctx, threadID, rootID, depth)
+	if err != nil {
+		return nil, err
+	}
+	return repo.BuildReplyTree(replies), nil
+}
+
+// PostReply answers the thread, or the reply parentID when it is not empty.
+func (t *ThreadService) PostReply(ctx context.Context, actor auth.Identity, threadID string, parentID string, content string) (repo.Reply, error) {
+	if parentID != "" {
+		parent, err := t.GetReply(ctx, threadID, parentID)
+		if errors.Is(err, repo.ErrReplyNotFound) {
+			return repo.Reply{}, repo.ErrParentNotFound
+		}
+		if err != nil {
+			return repo.Reply{}, err
+		}
+		if parent.Depth >= t.maxReplyDepth {
+			return repo.Reply{}, repo.NewValidationError(fmt.Sprintf("replies cannot nest deeper than %d levels", t.maxReplyDepth))
+		}
+	}
+	reply, err := t.AddReply(ctx, threadID, parentID, actor.Username, content)
+	if err != nil {
+		return repo.Reply{}, err
+	}
+
+	t.notify()
+	return reply, nil
+}
+
+func (t *ThreadService) UpdateReply(ctx context.Context, actor auth.Identity, threadID string, replyID string, content string) (repo.Reply, error) {
+	if err := t.authorizeReply(ctx, actor, threadID, replyID, auth.PermEditAnyThread); err != nil {
+		return repo.Reply{}, err
+	}
+	return t.EditReply(ctx, threadID, replyID, content)
+}
+
+func (t *ThreadService) RemoveReply(ctx context.Context, actor auth.Identity, threadID string, replyID string) error {
+	if err := t.authorizeReply(ctx, actor, threadID, replyID, auth.PermDeleteAnyThread); err != nil {
+		return err
+	}
+	return t.DeleteReply(ctx, threadID, replyID)
+}
@@ -2,25 +2,105 @@ package threads
 
 import (
 	"context"
+	"gofiber-api/auth"
 	repo "gofiber-api/repository"
 )
 
 type RepositoryThread interface {
 	GetThreads(ctx context.Context) []repo.Thread
-	AddThread(ctx context.Context, author string, content string) (string, error)
-	EditThread(ctx context.Context, id string, content string) error
+	ListThreads(ctx context.Context, opts repo.ListOptions) (repo.ThreadPage, error)
+	GetThreadByID(ctx context.Context, id string) (repo.Thread, error)
+	SearchThreads(ctx context.Context, query string, limit int) ([]repo.SearchResult, error)
+	AddThread(ctx context.Context, author string, content string, tags ...string) (repo.Thread, error)
+	EditThread(ctx context.Context, id string, edit repo.ThreadEdit) (repo.Thread, error)
 	DeleteThread(ctx context.Context, id string) error
+	GetRevisions(ctx context.Context, id string) ([]repo.Revision, error)
+	GetRevision(ctx context.Context, id string, number int) (repo.Revision, error)
+	GetTrash(ctx context.Context) ([]repo.Thread, error)
+	RestoreThread(ctx context.Context, id string) (repo.Thread, error)
+	GetReplies(ctx context.Context, threadID string) ([]repo.Reply, error)
+	GetReply(ctx context.Context, threadID string, replyID string) (repo.Reply, error)
+	GetReplyTree(ctx context.Context, threadID string, rootID string, depth int) ([]repo.Reply, error)
+	AddReply(ctx context.Context, threadID string, parentID string, author string, content string) (repo.Reply, error)
+	EditReply(ctx context.Context, threadID string, replyID string, content string) (repo.Reply, error)
+	DeleteReply(ctx context.Context, threadID string, replyID string) error
+	GetTags(ctx context.Context) ([]repo.TagCount, error)
+	AddReaction(ctx context.Context, threadID string, username string, kind string) (repo.Thread, error)
+	RemoveReaction(ctx context.Context, threadID string, username string, kind string) (repo.Thread, error)
 }
 
 // test this with mock tomorrow
 
+// DefaultMaxReplyDepth is how deep replies may nest unless configured
+// otherwise.
+const DefaultMaxReplyDepth = 8
+
+// Notifier is told that a change was stored, the events of the changes are
+// recorded in the outbox of the store.
+type Notifier interface {
+	Notify()
+}
+
 type ThreadService struct {
 	RepositoryThread
+	policy        *auth.Policy
+	maxReplyDepth int
+	notifiers     []Notifier
+}
+
+type ThreadOption func(*ThreadService)
+
+// WithPolicy replaces the default roles allowed to manage threads of others.
+func WithPolicy(policy *auth.Policy) ThreadOption {
+	return func(t *ThreadService) {
+		t.policy = policy
+	}
 }
 
-func NewThread(r RepositoryThread) *ThreadService {
-	return &ThreadService{
+// WithMaxReplyDepth limits how deep replies may nest, 1 only allows
+// replies to the thread itself.
+func WithMaxReplyDepth(depth int) ThreadOption {
+	return func(t *ThreadService) {
+		t.maxReplyDepth = depth
+	}
+}
+
+// WithNotifier notifies n after every change, it wakes up the relay of the
+// outbox.
+func WithNotifier(n Notifier) ThreadOption {
+	return func(t *ThreadService) {
+		t.notifiers = append(t.notifiers, n)
+	}
+}
+
+func NewThread(r RepositoryThread, opts ...ThreadOption) *ThreadService {
+	t := &ThreadService{
 		RepositoryThread: r,
+		policy:           auth.DefaultPolicy(),
+		maxReplyDepth:    DefaultMaxReplyDepth,
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+// authorize checks that actor may act on the thread, either as its author or
+// through a permission of their role.
+func (t *ThreadService) authorize(ctx context.Context, actor auth.Identity, id string, permission string) error {
+	thread, err := t.GetThreadByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if thread.Author != actor.Username && !t.policy.Allows(actor.Role, permission) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+func (t *ThreadService) notify() {
+	for _, n := range t.notifiers {
+		n.Notify()
 	}
 }
 
@@ -28,15 +108,106 @@ func (t *ThreadService) GetAll(ctx context.Context) []repo.Thread {
 	return t.GetThreads(ctx)
 }
 
-func (t *ThreadService) Add(ctx context.Context, author string, content string) error {
-	_, err := t.AddThread(ctx, author, content)
-	return err
+func (t *ThreadService) List(ctx context.Context, opts repo.ListOptions) (repo.ThreadPage, error) {
+	return t.ListThreads(ctx, opts)
+}
+
+func (t *ThreadService) Search(ctx context.Context, query string, limit int) ([]repo.SearchResult, error) {
+	return t.SearchThreads(ctx, query, limit)
+}
+
+func (t *ThreadService) Get(ctx context.Context, id string) (repo.Thread, error) {
+	return t.GetThreadByID(ctx, id)
+}
+
+func (t *ThreadService) Add(ctx context.Context, author string, content string, tags []string) (repo.Thread, error) {
+	thread, err := t.AddThread(ctx, author, content, tags...)
+	if err != nil {
+		return repo.Thread{}, err
+	}
+
+	t.notify()
+	return thread, nil
+}
+
+// Edit replaces the content and the tags of a thread, an empty content or
+// nil tags are kept. version guards against lost updates when it is not
+// zero.
+func (t *ThreadService) Edit(ctx context.Context, actor auth.Identity, id string, content string, tags []string, version int) (repo.Thread, error) {
+	if err := t.authorize(ctx, actor, id, auth.PermEditAnyThread); err != nil {
+		return repo.Thread{}, err
+	}
+	thread, err := t.EditThread(ctx, id, repo.ThreadEdit{
+		Content: content,
+		Tags:    tags,
+		Editor:  actor.Username,
+		Version: version,
+	})
+	if err != nil {
+		return repo.Thread{}, err
+	}
+
+	t.notify()
+	return thread, nil
+}
+
+// Delete moves the thread to the trash, see Restore.
+func (t *ThreadService) Delete(ctx context.Context, actor auth.Identity, id string) error {
+	if err := t.authorize(ctx, actor, id, auth.PermDeleteAnyThread); err != nil {
+		return err
+	}
+	if err := t.DeleteThread(ctx, id); err != nil {
+		return err
+	}
+
+	t.notify()
+	return nil
+}
+
+func (t *ThreadService) Revisions(ctx context.Context, id string) ([]repo.Revision, error) {
+	return t.GetRevisions(ctx, id)
+}
+
+func (t *ThreadService) Revision(ctx context.Context, id string, number int) (repo.Revision, error) {
+	return t.GetRevision(ctx, id, number)
+}
+
+// RestoreRevision brings back the content of a previous revision as a new
+// edit, so the history is kept.
+func (t *ThreadService) RestoreRevision(ctx context.Context, actor auth.Identity, id string, number int, version int) (repo.Thread, error) {
+	revision, err := t.GetRevision(ctx, id, number)
+	if err != nil {
+		return repo.Thread{}, err
+	}
+	return t.Edit(ctx, actor, id, revision.Content, nil, version)
+}
+
+func (t *ThreadService) Trash(ctx context.Context, actor auth.Identity) ([]repo.Thread, error) {
+	if !t.policy.Allows(actor.Role, auth.PermManageTrash) {
+		return nil, auth.ErrForbidden
+	}
+	return t.GetTrash(ctx)
+}
+
+// Restore takes a deleted thread out of the trash.
+func (t *ThreadService) Restore(ctx context.Context, actor auth.Identity, id string) (repo.Thread, error) {
+	if !t.policy.Allows(actor.Role, auth.PermManageTrash) {
+		return repo.Thread{}, auth.ErrForbidden
+	}
+	return t.RestoreThread(ctx, id)
+}
+
+// Tags returns the tags in use, most used first.
+func (t *ThreadService) Tags(ctx context.Context) ([]repo.TagCount, error) {
+	return t.GetTags(ctx)
 }
 
-func (t *ThreadService) Edit(ctx context.Context, id string, content string) error {
-	return t.EditThread(ctx, id, content)
+// React leaves a reaction of actor on the thread, reacting again with the
+// same kind changes nothing.
+func (t *ThreadService) React(ctx context.Context, actor auth.Identity, id string, kind string) (repo.Thread, error) {
+	return t.AddReaction(ctx, id, actor.Username, kind)
 }
 
-func (t *ThreadService) Delete(ctx context.Context, id string) error {
-	return t.DeleteThread(ctx, id)
+func (t *ThreadService) Unreact(ctx context.Context, actor auth.Identity, id string, kind string) (repo.Thread, error) {
+	return t.RemoveReaction(ctx, id, actor.Username, kind)
 }
@@ -0,0 +1,111 @@
+package threads
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"gofiber-api/auth"
+	repo "gofiber-api/repository"
+	"time"
+)
+
+type RepositoryWebhook interface {
+	GetWebhooks(ctx context.Context) ([]repo.Webhook, error)
+	GetWebhook(ctx context.Context, id string) (repo.Webhook, error)
+	AddWebhook(ctx context.Context, url string, events []string, secret string) (repo.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	GetDeliveries(ctx context.Context, webhookID string, status string) ([]repo.Delivery, error)
+	DueDeliveries(ctx context.Context, now time.Time, limit int) ([]repo.Delivery, error)
+	AddDelivery(ctx context.Context, webhookID string, event string, payload []byte) (repo.Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery repo.Delivery) (repo.Delivery, error)
+}
+
+// WebhookService manages the webhooks, every method requires
+// auth.PermManageWebhooks.
+type WebhookService struct {
+	RepositoryWebhook
+	policy *auth.Policy
+}
+
+func NewWebhook(r RepositoryWebhook, policy *auth.Policy) *WebhookService {
+	return &WebhookService{
+		RepositoryWebhook: r,
+		policy:            policy,
+	}
+}
+
+func (w *WebhookService) authorize(actor auth.Identity) error {
+	if !w.policy.Allows(actor.Role, auth.PermManageWebhooks) {
+		return auth.ErrForbidden
+	}
+	return nil
+}
+
+// Webhooks lists the webhooks without their secrets.
+func (w *WebhookService) Webhooks(ctx context.Context, actor auth.Identity) ([]repo.Webhook, error) {
+	if err := w.authorize(actor); err != nil {
+		return nil, err
+	}
+	webhooks, err := w.GetWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+	return webhooks, nil
+}
+
+// Webhook returns a webhook without its secret.
+func (w *WebhookService) Webhook(ctx context.Context, actor auth.Identity, id string) (repo.Webhook, error) {
+	if err := w.authorize(actor); err != nil {
+		return repo.Webhook{}, err
+	}
+	webhook, err := w.GetWebhook(ctx, id)
+	if err != nil {
+		return repo.Webhook{}, err
+	}
+	webhook.Secret = ""
+	return webhook, nil
+}
+
+// Register adds a webhook for events with a new secret, the returned
+// webhook is the only one holding the secret.
+func (w *WebhookService) Register(ctx context.Context, actor auth.Identity, url string, events []string) (repo.Webhook, error) {
+	if err := w.authorize(actor); err != nil {
+		return repo.Webhook{}, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return repo.Webhook{}, fmt.Errorf("%w: %v", repo.ErrInternal, err)
+	}
+	return w.AddWebhook(ctx, url, events, hex.EncodeToString(secret))
+}
+
+// Unregister removes a webhook with its pending deliveries.
+func (w *WebhookService) Unregister(ctx context.Context, actor auth.Identity, id string) error {
+	if err := w.authorize(actor); err != nil {
+		return err
+	}
+	return w.DeleteWebhook(ctx, id)
+}
+
+// Deliveries returns the delivery log of a webhook, a non empty status only
+// keeps the deliveries in that state.
+func (w *WebhookService) Deliveries(ctx context.Context, actor auth.Identity, webhookID string, status string) ([]repo.Delivery, error) {
+	if err := w.authorize(actor); err != nil {
+		return nil, err
+	}
+	return w.GetDeliveries(ctx, webhookID, status)
+}
+
+// DeadLetters returns the deliveries of every webhook that ran out of
+// attempts.
+func (w *WebhookService) DeadLetters(ctx context.Context, actor auth.Identity) ([]repo.Delivery, error) {
+	if err := w.authorize(actor); err != nil {
+		return nil, err
+	}
+	return w.GetDeliveries(ctx, "", repo.DeliveryDead)
+}
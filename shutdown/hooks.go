@@ -0,0 +1,74 @@
+package shutdown
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Hook releases something when the server stops, it should give up once
+// ctx is done.
+type Hook func(ctx context.Context) error
+
+type namedHook struct {
+	name string
+	hook Hook
+}
+
+// Hooks runs the registered hooks when the server stops. They run in the
+// reverse order of their registration, like deferred calls, so what was
+// started last is stopped first.
+type Hooks struct {
+	mu    sync.Mutex
+	hooks []namedHook
+}
+
+func NewHooks() *Hooks {
+	return &Hooks{}
+}
+
+func (h *Hooks) Add(name string, hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.hooks = append(h.hooks, namedHook{name: name, hook: hook})
+}
+
+// Go runs a background worker until the server stops, its hook cancels the
+// context given to run and waits for run to return.
+func (h *Hooks) Go(name string, run func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		run(ctx)
+	}()
+
+	h.Add(name, func(ctx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// Run runs every hook once, even after one of them failed, and returns
+// their errors. The hooks are forgotten, a second Run does nothing.
+func (h *Hooks) Run(ctx context.Context) error {
+	h.mu.Lock()
+	hooks := h.hooks
+	h.hooks = nil
+	h.mu.Unlock()
+
+	var errs []error
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i].hook(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("stopping %s: %w", hooks[i].name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
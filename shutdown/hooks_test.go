@@ -0,0 +1,85 @@
+package shutdown_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/suite"
+
+	"gofiber-api/shutdown"
+)
+
+type HooksSuite struct {
+	suite.Suite
+	hooks *shutdown.Hooks
+}
+
+func TestHooksSuite(t *testing.T) {
+	suite.Run(t, new(HooksSuite))
+}
+
+func (s *HooksSuite) SetupTest() {
+	s.hooks = shutdown.NewHooks()
+}
+
+func (s *HooksSuite) TestRunInReverseOrder() {
+	ran := []string{}
+	for _, name := range []string{"store", "worker", "server"} {
+		name := name
+		s.hooks.Add(name, func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+
+	s.NoError(s.hooks.Run(context.Background()))
+	s.Equal([]string{"server", "worker", "store"}, ran)
+
+	// the hooks only run once
+	s.NoError(s.hooks.Run(context.Background()))
+	s.Equal(3, len(ran))
+}
+
+func (s *HooksSuite) TestRunReportsEveryError() {
+	failure := errors.New("the failure")
+	ran := false
+	s.hooks.Add("store", func(ctx context.Context) error {
+		ran = true
+		return nil
+	})
+	s.hooks.Add("server", func(ctx context.Context) error {
+		return failure
+	})
+
+	err := s.hooks.Run(context.Background())
+	s.ErrorIs(err, failure)
+	s.ErrorContains(err, "stopping server")
+	s.True(ran)
+}
+
+func (s *HooksSuite) TestGoStopsWorker() {
+	stopped := false
+	s.hooks.Go("worker", func(ctx context.Context) {
+		<-ctx.Done()
+		stopped = true
+	})
+
+	s.NoError(s.hooks.Run(context.Background()))
+	s.True(stopped)
+}
+
+func (s *HooksSuite) TestGoGivesUpAtDeadline() {
+	release := make(chan struct{})
+	defer close(release)
+	s.hooks.Go("stuck", func(ctx context.Context) {
+		<-release
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := s.hooks.Run(ctx)
+	s.ErrorIs(err, context.DeadlineExceeded)
+	s.ErrorContains(err, "stopping stuck")
+}